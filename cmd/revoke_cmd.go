@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/hiumesh/go-chat-server/internal/redis_storage"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var revokeSessionId = ""
+var revokeUserId = ""
+
+var revokeCmd = cobra.Command{
+	Use:  "revoke",
+	Long: "Revoke a session or every session of a user and close their connections.",
+	Run:  revoke,
+}
+
+func init() {
+	revokeCmd.Flags().StringVar(&revokeSessionId, "session", "", "the session id to revoke")
+	revokeCmd.Flags().StringVar(&revokeUserId, "user", "", "the user id whose sessions to revoke")
+}
+
+func revoke(cmd *cobra.Command, args []string) {
+	if (revokeSessionId == "") == (revokeUserId == "") {
+		logrus.Fatalf("exactly one of --session or --user is required")
+	}
+
+	globalConfig := loadGlobalConfig(cmd.Context())
+
+	redisDb, err := redis_storage.Dial(cmd.Context(), &globalConfig.REDIS)
+	if err != nil {
+		logrus.Fatalf("error opening redis database: %+v", err)
+	}
+	defer redisDb.Close()
+
+	bus, err := websocket.NewBus(&globalConfig.BUS, redisDb)
+	if err != nil {
+		logrus.Fatalf("error creating the message bus: %+v", err)
+	}
+	defer bus.Close()
+
+	revoker := websocket.NewRevoker(redisDb, websocket.NewRedisRegistry(redisDb), bus, globalConfig.SERVER.RevocationTTL)
+	if revokeSessionId != "" {
+		err = revoker.RevokeSession(cmd.Context(), revokeSessionId)
+	} else {
+		err = revoker.RevokeUser(cmd.Context(), revokeUserId)
+	}
+	if err != nil {
+		logrus.Fatalf("error revoking: %+v", err)
+	}
+
+	logrus.Infof("revoked.")
+}
@@ -10,18 +10,24 @@ import (
 )
 
 var configFile = ""
+var standalone = false
+var channelMembersFile = ""
 
 var rootCmd = cobra.Command{
 	Use: "gosocket",
 	Run: func(cmd *cobra.Command, args []string) {
-		migrate(cmd, args)
+		if !standalone {
+			migrate(cmd, args)
+		}
 		serve(cmd)
 	},
 }
 
 func RootCommand() *cobra.Command {
-	rootCmd.AddCommand(&serveCmd, &migrateCmd)
+	rootCmd.AddCommand(&serveCmd, &migrateCmd, &revokeCmd)
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "the config file to use")
+	rootCmd.PersistentFlags().BoolVar(&standalone, "standalone", false, "keep storage, the connection registry and the bus in process")
+	rootCmd.PersistentFlags().StringVar(&channelMembersFile, "channel-members", "", "with --standalone, a JSON file mapping channel ids to the ids of their members")
 
 	return &rootCmd
 }
@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"context"
 	"net"
 
 	"github.com/hiumesh/go-chat-server/internal/api"
 	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/hiumesh/go-chat-server/internal/memory_storage"
+	"github.com/hiumesh/go-chat-server/internal/models"
+	"github.com/hiumesh/go-chat-server/internal/observability"
 	"github.com/hiumesh/go-chat-server/internal/redis_storage"
 	"github.com/hiumesh/go-chat-server/internal/scylla_storage"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
+	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -25,19 +31,64 @@ func serve(cmd *cobra.Command) {
 		logrus.WithError(err).Fatal("unable to load config")
 	}
 
-	db, err := scylla_storage.Dial(&globalConfig.DB)
+	shutdownTracing, err := observability.ConfigureTracing(cmd.Context(), &globalConfig.TRACING)
 	if err != nil {
-		logrus.Fatalf("error opening scylla database: %+v", err)
+		logrus.Fatalf("error configuring tracing: %+v", err)
 	}
-	defer db.Close()
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logrus.Errorf("error flushing traces: %v", err)
+		}
+	}()
 
-	redisDb, err := redis_storage.Dial(cmd.Context(), &globalConfig.REDIS)
+	var store models.Store
+	var registry websocket.ConnectionRegistry
+	var redisDb *redis.Client
+
+	if standalone {
+		logrus.Infof("running standalone, data is kept in memory")
+
+		memoryStore := memory_storage.NewStore()
+		if channelMembersFile != "" {
+			if err := memoryStore.LoadChannelUsers(channelMembersFile); err != nil {
+				logrus.Fatalf("error loading channel members: %+v", err)
+			}
+		}
+		store = memoryStore
+
+		var closeRedis func()
+		redisDb, closeRedis, err = redis_storage.DialEmbedded(cmd.Context())
+		if err != nil {
+			logrus.Fatalf("error starting embedded redis: %+v", err)
+		}
+		defer closeRedis()
+		defer redisDb.Close()
+
+		registry = websocket.NewMemoryRegistry()
+		globalConfig.BUS.Driver = "memory"
+	} else {
+		db, err := scylla_storage.Dial(&globalConfig.DB)
+		if err != nil {
+			logrus.Fatalf("error opening scylla database: %+v", err)
+		}
+		defer db.Close()
+		store = models.NewScyllaStore(db)
+
+		redisDb, err = redis_storage.Dial(cmd.Context(), &globalConfig.REDIS)
+		if err != nil {
+			logrus.Fatalf("error opening redis database: %+v", err)
+		}
+		defer redisDb.Close()
+		registry = websocket.NewRedisRegistry(redisDb)
+	}
+
+	bus, err := websocket.NewBus(&globalConfig.BUS, redisDb)
 	if err != nil {
-		logrus.Fatalf("error opening redis database: %+v", err)
+		logrus.Fatalf("error creating the message bus: %+v", err)
 	}
-	defer redisDb.Close()
+	defer bus.Close()
 
-	api := api.NewAPIWithVersion(cmd.Context(), globalConfig, db, redisDb, "latest")
+	api := api.NewAPIWithVersion(cmd.Context(), globalConfig, store, redisDb, registry, bus, "latest")
 
 	addr := net.JoinHostPort(globalConfig.API.Host, globalConfig.API.Port)
 	logrus.Infof("GoTrue API started on: %s", addr)
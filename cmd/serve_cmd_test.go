@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	_websocket "github.com/gorilla/websocket"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
+	"github.com/spf13/cobra"
+)
+
+const testSecret = "secret"
+
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func testToken(t *testing.T, subject string) string {
+	t.Helper()
+
+	claims := &utils.AccessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// startStandalone runs `serve --standalone` until the test ends.
+func startStandalone(t *testing.T) string {
+	t.Helper()
+
+	port := freePort(t)
+	t.Setenv("GO_SOCKET_JWT_SECRET", testSecret)
+	t.Setenv("GO_SOCKET_PORT", port)
+
+	standalone = true
+	t.Cleanup(func() { standalone = false })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := &cobra.Command{}
+	cmd.SetContext(ctx)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		serve(cmd)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	addr := net.JoinHostPort("127.0.0.1", port)
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		response, err := http.Get("http://" + addr + "/")
+		if err == nil {
+			response.Body.Close()
+			return addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server not started: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func dialTestClient(t *testing.T, addr string, subject string) *_websocket.Conn {
+	t.Helper()
+
+	header := http.Header{
+		"Authorization": {"Bearer " + testToken(t, subject)},
+		"Origin":        {"http://localhost:8080"},
+	}
+	conn, _, err := _websocket.DefaultDialer.Dial("ws://"+addr+"/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readEventOfType(t *testing.T, conn *_websocket.Conn, eventType string) websocket.Event {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var event websocket.Event
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("no %q event: %v", eventType, err)
+		}
+		if event.Type == eventType {
+			return event
+		}
+	}
+}
+
+func TestServeStandalone(t *testing.T) {
+	addr := startStandalone(t)
+	alice := dialTestClient(t, addr, "alice")
+	bob := dialTestClient(t, addr, "bob")
+
+	payload, err := json.Marshal(websocket.SendDirectMessageEvent{ClientMessageId: "1", To: "bob", Body: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.WriteJSON(websocket.Event{Type: websocket.EventSendDirectMessage, Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+
+	readEventOfType(t, alice, websocket.EventMessageAck)
+
+	var message websocket.NewMessageEvent
+	if err := json.Unmarshal(readEventOfType(t, bob, websocket.EventNewMessage).Payload, &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Body != "hello" || message.From != "alice" {
+		t.Errorf("got %+v, want hello from alice", message)
+	}
+
+	// The message is kept by the in-memory store.
+	request, err := http.NewRequest(http.MethodGet, "http://"+addr+"/users/alice/messages", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+testToken(t, "bob"))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var history struct {
+		Messages []json.RawMessage `json:"messages"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK || len(history.Messages) != 1 {
+		t.Errorf("got status %d with %d messages, want 1 message", response.StatusCode, len(history.Messages))
+	}
+}
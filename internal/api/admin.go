@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+)
+
+// ListConnections returns the egress queue of every connection of this node.
+func (a *API) ListConnections(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, a.manager.EgressStats())
+}
+
+func (a *API) RevokeSession(ctx *gin.Context) {
+	if err := a.manager.Revoker().RevokeSession(ctx, ctx.Param("session_id")); err != nil {
+		utils.HandleHttpError(utils.InternalServerError("unable to revoke the session").WithInternalError(err), ctx)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (a *API) RevokeUserSessions(ctx *gin.Context) {
+	if err := a.manager.Revoker().RevokeUser(ctx, ctx.Param("user_id")); err != nil {
+		utils.HandleHttpError(utils.InternalServerError("unable to revoke the sessions").WithInternalError(err), ctx)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -11,9 +11,12 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/hiumesh/go-chat-server/internal/models"
+	"github.com/hiumesh/go-chat-server/internal/observability"
+	"github.com/hiumesh/go-chat-server/internal/utils"
 	"github.com/hiumesh/go-chat-server/internal/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
-	"github.com/scylladb/gocqlx/v2"
 	"github.com/sirupsen/logrus"
 )
 
@@ -24,31 +27,42 @@ const (
 var bearerRegexp = regexp.MustCompile(`^(?:B|b)earer (\S+$)`)
 
 type API struct {
-	handler *gin.Engine
-	db      gocqlx.Session
-	config  *conf.GlobalConfiguration
-	version string
+	handler     *gin.Engine
+	store       models.Store
+	manager     *websocket.Manager
+	tokenParser *utils.TokenParser
+	rdb         *redis.Client
+	config      *conf.GlobalConfiguration
+	version     string
 }
 
-func NewAPI(globalConfig *conf.GlobalConfiguration, db gocqlx.Session, redisDb *redis.Client) *API {
-	return NewAPIWithVersion(context.Background(), globalConfig, db, redisDb, defaultVersion)
+func NewAPI(globalConfig *conf.GlobalConfiguration, store models.Store, redisDb *redis.Client, registry websocket.ConnectionRegistry, bus websocket.Bus) *API {
+	return NewAPIWithVersion(context.Background(), globalConfig, store, redisDb, registry, bus, defaultVersion)
 }
 
-func NewAPIWithVersion(ctx context.Context, globalConfig *conf.GlobalConfiguration, db gocqlx.Session, redisDb *redis.Client, version string) *API {
-	api := API{config: globalConfig, db: db, version: version}
+func NewAPIWithVersion(ctx context.Context, globalConfig *conf.GlobalConfiguration, store models.Store, redisDb *redis.Client, registry websocket.ConnectionRegistry, bus websocket.Bus, version string) *API {
+	api := API{config: globalConfig, store: store, rdb: redisDb, version: version}
+	api.tokenParser = newTokenParser(ctx, &globalConfig.JWT)
 
 	router := gin.Default()
 
 	corsHandler := cors.New(cors.Config{
-		AllowAllOrigins:  true,
-		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowOriginFunc: func(origin string) bool {
+			if globalConfig.CORS.AllowsOrigin(origin) {
+				return true
+			}
+			observability.RecordRejectedOrigin("cors", origin)
+			return false
+		},
+		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
 		AllowHeaders:     globalConfig.CORS.AllAllowedHeaders([]string{"Accept", "Authorization", "Content-Type", "X-Client-IP", "X-Client-Info"}),
 		ExposeHeaders:    []string{"X-Total-Count", "Link"},
 		AllowCredentials: true,
 	})
 	router.Use(corsHandler)
 
-	manager := websocket.NewManager(ctx, globalConfig, redisDb, db)
+	manager := websocket.NewManager(ctx, globalConfig, redisDb, store, registry, bus, api.tokenParser)
+	api.manager = manager
 
 	router.Use(addUniqueRequestID(globalConfig))
 
@@ -56,9 +70,41 @@ func NewAPIWithVersion(ctx context.Context, globalConfig *conf.GlobalConfigurati
 		ctx.JSON(200, gin.H{"message": "ping"})
 	})
 
+	metrics := gin.WrapH(promhttp.Handler())
+	if globalConfig.API.PublicMetrics {
+		router.GET("/metrics", metrics)
+	}
+	if globalConfig.API.PublicSchemas {
+		api.routeSchemas(router)
+	}
+
 	router.Use(api.requireAuthentication).GET("/ws", func(ginCtx *gin.Context) {
 		manager.ServeWS(ginCtx)
 	})
+	router.GET("/sse", manager.ServeSSE)
+	router.GET("/poll", manager.ServePoll)
+	router.POST("/events", api.PostEvent)
+
+	router.POST("/tickets", api.CreateTicket)
+	router.GET("/conversations", api.ListConversations)
+	router.GET("/channels/:channel_id/messages", api.ListChannelMessages)
+	router.GET("/users/:user_id/messages", api.ListDirectMessages)
+	router.PATCH("/messages/:message_id", api.EditMessage)
+	router.DELETE("/messages/:message_id", api.DeleteMessage)
+	router.GET("/messages/:message_id/edits", api.ListMessageEdits)
+	router.GET("/messages/:message_id/replies", api.ListThreadMessages)
+
+	admin := router.Group("/admin", api.requireAdmin)
+	admin.GET("/connections", api.ListConnections)
+	admin.DELETE("/sessions/:session_id", api.RevokeSession)
+	admin.DELETE("/users/:user_id/sessions", api.RevokeUserSessions)
+
+	if !globalConfig.API.PublicMetrics {
+		router.GET("/metrics", api.requireAdmin, metrics)
+	}
+	if !globalConfig.API.PublicSchemas {
+		api.routeSchemas(router.Group("", api.requireAdmin))
+	}
 
 	api.handler = router
 	return &api
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/hiumesh/go-chat-server/internal/memory_storage"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
+	"github.com/redis/go-redis/v9"
+)
+
+const testSecret = "secret"
+
+func newTestAPI(t *testing.T, configure func(*conf.GlobalConfiguration)) *API {
+	t.Helper()
+
+	t.Setenv("GO_SOCKET_JWT_SECRET", testSecret)
+	config, err := conf.LoadGlobal("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configure != nil {
+		configure(config)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewAPIWithVersion(ctx, config, memory_storage.NewStore(), rdb, websocket.NewMemoryRegistry(), websocket.NewMemoryBus(), "test")
+}
+
+func testToken(t *testing.T, subject string, role string) string {
+	t.Helper()
+
+	claims := &utils.AccessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Role: role,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func serveTestRequest(a *API, method string, path string, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	a.handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestMetricsAndSchemasRequireAdmin(t *testing.T) {
+	a := newTestAPI(t, nil)
+	userToken := testToken(t, "alice", "authenticated")
+	adminToken := testToken(t, "ops", a.config.JWT.AdminRole)
+
+	for _, path := range []string{"/metrics", "/schemas/events.json", "/schemas/event.proto", "/admin/connections"} {
+		t.Run(path, func(t *testing.T) {
+			tests := []struct {
+				token  string
+				status int
+			}{
+				{"", http.StatusUnauthorized},
+				{userToken, http.StatusForbidden},
+				{adminToken, http.StatusOK},
+			}
+			for _, test := range tests {
+				if recorder := serveTestRequest(a, http.MethodGet, path, test.token); recorder.Code != test.status {
+					t.Errorf("got status %d, want %d", recorder.Code, test.status)
+				}
+			}
+		})
+	}
+}
+
+func TestPublicMetricsAndSchemas(t *testing.T) {
+	a := newTestAPI(t, func(config *conf.GlobalConfiguration) {
+		config.API.PublicMetrics = true
+		config.API.PublicSchemas = true
+	})
+
+	for _, path := range []string{"/metrics", "/schemas/events.json", "/schemas/event.proto"} {
+		if recorder := serveTestRequest(a, http.MethodGet, path, ""); recorder.Code != http.StatusOK {
+			t.Errorf("got status %d for %s, want %d", recorder.Code, path, http.StatusOK)
+		}
+	}
+	if recorder := serveTestRequest(a, http.MethodGet, "/admin/connections", ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d for the admin routes, want %d", recorder.Code, http.StatusUnauthorized)
+	}
+}
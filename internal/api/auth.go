@@ -2,30 +2,85 @@ package api
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+	_websocket "github.com/gorilla/websocket"
+	"github.com/hiumesh/go-chat-server/internal/conf"
 	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
 	"github.com/sirupsen/logrus"
 )
 
+// newTokenParser loads the JWKS, when configured, and keeps it refreshed for
+// the lifetime of the context.
+func newTokenParser(ctx context.Context, config *conf.JWTConfiguration) *utils.TokenParser {
+	var keys *utils.KeySet
+	if config.JWKS != "" {
+		keys = utils.NewKeySet(config.JWKS)
+		if err := keys.Refresh(ctx); err != nil {
+			logrus.Errorf("error loading jwks: %v", err)
+		}
+		go keys.Watch(ctx, config.JWKSRefreshInterval)
+	}
+
+	return utils.NewTokenParser(config.Secret, keys, config.Audience, config.Issuer)
+}
+
+// streamRoutes accept credentials browsers can attach to a websocket upgrade
+// or an EventSource. The other routes only accept the Authorization header,
+// which a cross-site request cannot carry.
+var streamRoutes = map[string]bool{
+	"/ws":   true,
+	"/sse":  true,
+	"/poll": true,
+}
+
+// extractBearerToken reads the token from the Authorization header, falling
+// back on the stream routes to a ticket query parameter, the websocket
+// subprotocols and the access token cookie since browsers cannot set headers
+// on websocket upgrades.
 func (a *API) extractBearerToken(ctx *gin.Context) (string, *utils.HTTPError) {
 	authHeader := ctx.Request.Header.Get("Authorization")
-	matches := bearerRegexp.FindStringSubmatch(authHeader)
-	if len(matches) != 2 {
+	if matches := bearerRegexp.FindStringSubmatch(authHeader); len(matches) == 2 {
+		return matches[1], nil
+	}
+
+	if !streamRoutes[ctx.FullPath()] {
 		return "", utils.UnauthorizedError("This endpoint requires a Bearer token")
 	}
 
-	return matches[1], nil
+	if ticket := ctx.Query("ticket"); ticket != "" {
+		return a.redeemTicket(ctx, ticket)
+	}
+
+	if token := bearerSubprotocol(ctx.Request); token != "" {
+		return token, nil
+	}
+
+	if a.config.COOKIE.Key != "" {
+		if cookie, err := ctx.Request.Cookie(a.config.COOKIE.Key + "-access-token"); err == nil && cookie.Value != "" {
+			return cookie.Value, nil
+		}
+	}
+
+	return "", utils.UnauthorizedError("This endpoint requires a Bearer token")
 }
 
-func (a *API) parseJWTClaims(bearer string, ctx *gin.Context) (context.Context, *utils.HTTPError) {
-	config := a.config
+// bearerSubprotocol returns the token offered as the subprotocol following
+// websocket.BearerSubprotocol in the Sec-WebSocket-Protocol header.
+func bearerSubprotocol(r *http.Request) string {
+	protocols := _websocket.Subprotocols(r)
+	for i, protocol := range protocols {
+		if protocol == websocket.BearerSubprotocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
 
-	p := jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Name}}
-	token, err := p.ParseWithClaims(bearer, &utils.AccessTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.JWT.Secret), nil
-	})
+func (a *API) parseJWTClaims(bearer string, ctx *gin.Context) (context.Context, *utils.HTTPError) {
+	token, err := a.tokenParser.Parse(ctx, bearer)
 	if err != nil {
 		return ctx, utils.UnauthorizedError("invalid JWT: unable to parse or verify signature, %v", err)
 	}
@@ -51,4 +106,25 @@ func (a *API) requireAuthentication(ctx *gin.Context) {
 		return
 	}
 
+	revoked, revokeErr := a.manager.Revoker().IsRevoked(ctx, utils.GetClaims(ctx))
+	if revokeErr != nil {
+		logrus.Errorf("error checking session revocation: %v", revokeErr)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, utils.InternalServerError("unable to verify the session"))
+		return
+	}
+	if revoked {
+		a.clearCookieTokens(config, ctx.Writer)
+		err = utils.UnauthorizedError("session has been revoked")
+		ctx.AbortWithStatusJSON(err.Code, err)
+		return
+	}
+}
+
+func (a *API) requireAdmin(ctx *gin.Context) {
+	claims := utils.GetClaims(ctx)
+	if claims == nil || claims.Role != a.config.JWT.AdminRole {
+		err := utils.ForbiddenError("This endpoint requires the %s role", a.config.JWT.AdminRole)
+		ctx.AbortWithStatusJSON(err.Code, err)
+		return
+	}
 }
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+)
+
+func (a *API) ListConversations(ctx *gin.Context) {
+	claims := utils.GetClaims(ctx)
+	if claims == nil {
+		utils.HandleHttpError(utils.UnauthorizedError("claims not found"), ctx)
+		return
+	}
+
+	conversations, err := a.store.ListUserConversations(claims.Subject)
+	if err != nil {
+		utils.HandleHttpError(utils.InternalServerError("failed to list conversations").WithInternalError(err), ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"conversations": conversations})
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
+)
+
+// PostEvent takes the events of clients connected through /sse or /poll,
+// which cannot send them on their connection.
+func (a *API) PostEvent(ctx *gin.Context) {
+	claims := utils.GetClaims(ctx)
+	if claims == nil {
+		utils.HandleHttpError(utils.UnauthorizedError("claims not found"), ctx)
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, websocket.MaxEventSize)
+
+	var event websocket.Event
+	if err := ctx.ShouldBindJSON(&event); err != nil {
+		utils.HandleHttpError(utils.BadRequestError("bad payload in request"), ctx)
+		return
+	}
+
+	if err := a.manager.HandleEvent(ctx, claims, ctx.Query("connection_id"), event); err != nil {
+		utils.HandleHttpError(chatHttpError(err), ctx)
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
@@ -1,10 +1,14 @@
 package api
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/hiumesh/go-chat-server/internal/models"
 	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
 )
 
 func addUniqueRequestID(globalConfig *conf.GlobalConfiguration) gin.HandlerFunc {
@@ -16,3 +20,26 @@ func addUniqueRequestID(globalConfig *conf.GlobalConfiguration) gin.HandlerFunc
 
 	}
 }
+
+// chatHttpError maps the errors returned by the chat operations of the
+// websocket manager to their HTTP counterparts.
+func chatHttpError(err error) *utils.HTTPError {
+	switch {
+	case errors.Is(err, models.ErrMessageNotFound),
+		errors.Is(err, websocket.ErrConnectionNotFound):
+		return utils.NotFoundError("%v", err)
+	case errors.Is(err, websocket.ErrNotChannelMember),
+		errors.Is(err, websocket.ErrNotConversationParticipant),
+		errors.Is(err, websocket.ErrNotMessageOwner):
+		return utils.ForbiddenError("%v", err)
+	case errors.Is(err, websocket.ErrMessageDeleted):
+		return utils.ConflictError("%v", err)
+	case errors.Is(err, websocket.ErrConversationRequired),
+		errors.Is(err, websocket.ErrEventNotSupported),
+		errors.Is(err, models.ErrInvalidCursor),
+		errors.Is(err, models.ErrInvalidThreadRoot):
+		return utils.BadRequestError("%v", err)
+	default:
+		return utils.InternalServerError("unexpected error").WithInternalError(err)
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
+)
+
+func (a *API) ListChannelMessages(ctx *gin.Context) {
+	a.listMessages(ctx, websocket.FetchHistoryEvent{ChannelId: ctx.Param("channel_id")})
+}
+
+func (a *API) ListDirectMessages(ctx *gin.Context) {
+	a.listMessages(ctx, websocket.FetchHistoryEvent{UserId: ctx.Param("user_id")})
+}
+
+func (a *API) listMessages(ctx *gin.Context, request websocket.FetchHistoryEvent) {
+	claims := utils.GetClaims(ctx)
+	if claims == nil {
+		utils.HandleHttpError(utils.UnauthorizedError("claims not found"), ctx)
+		return
+	}
+
+	request.Cursor = ctx.Query("cursor")
+	if limit := ctx.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil {
+			utils.HandleHttpError(utils.BadRequestError("limit must be a number"), ctx)
+			return
+		}
+		request.Limit = parsed
+	}
+
+	history, err := a.manager.FetchHistory(claims, request)
+	if err != nil {
+		utils.HandleHttpError(chatHttpError(err), ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
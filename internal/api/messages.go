@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
+)
+
+func (a *API) EditMessage(ctx *gin.Context) {
+	claims := utils.GetClaims(ctx)
+	if claims == nil {
+		utils.HandleHttpError(utils.UnauthorizedError("claims not found"), ctx)
+		return
+	}
+
+	var request websocket.EditMessageEvent
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.HandleHttpError(utils.BadRequestError("bad payload in request"), ctx)
+		return
+	}
+
+	message, err := a.manager.EditMessage(ctx, claims, ctx.Param("message_id"), request.Body)
+	if err != nil {
+		utils.HandleHttpError(chatHttpError(err), ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, message)
+}
+
+func (a *API) DeleteMessage(ctx *gin.Context) {
+	claims := utils.GetClaims(ctx)
+	if claims == nil {
+		utils.HandleHttpError(utils.UnauthorizedError("claims not found"), ctx)
+		return
+	}
+
+	message, err := a.manager.DeleteMessage(ctx, claims, ctx.Param("message_id"))
+	if err != nil {
+		utils.HandleHttpError(chatHttpError(err), ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, message)
+}
+
+func (a *API) ListMessageEdits(ctx *gin.Context) {
+	claims := utils.GetClaims(ctx)
+	if claims == nil {
+		utils.HandleHttpError(utils.UnauthorizedError("claims not found"), ctx)
+		return
+	}
+
+	edits, err := a.manager.ListMessageEdits(claims, ctx.Param("message_id"))
+	if err != nil {
+		utils.HandleHttpError(chatHttpError(err), ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"edits": edits})
+}
+
+func (a *API) ListThreadMessages(ctx *gin.Context) {
+	claims := utils.GetClaims(ctx)
+	if claims == nil {
+		utils.HandleHttpError(utils.UnauthorizedError("claims not found"), ctx)
+		return
+	}
+
+	request := websocket.FetchThreadEvent{ThreadRootId: ctx.Param("message_id"), Cursor: ctx.Query("cursor")}
+	if limit := ctx.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil {
+			utils.HandleHttpError(utils.BadRequestError("limit must be a number"), ctx)
+			return
+		}
+		request.Limit = parsed
+	}
+
+	thread, err := a.manager.FetchThread(claims, request)
+	if err != nil {
+		utils.HandleHttpError(chatHttpError(err), ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, thread)
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/websocket"
+)
+
+// EventSchemas serves the JSON Schema of the event envelopes and payloads.
+func (a *API) EventSchemas(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, websocket.EventSchemas())
+}
+
+// EventProto serves the protobuf definition of the event envelopes.
+func (a *API) EventProto(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/plain; charset=utf-8", websocket.EventProto)
+}
+
+func (a *API) routeSchemas(routes gin.IRoutes) {
+	routes.GET("/schemas/events.json", a.EventSchemas)
+	routes.GET("/schemas/event.proto", a.EventProto)
+}
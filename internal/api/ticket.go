@@ -0,0 +1,58 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+type TicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+func ticketKey(ticket string) string {
+	return "ticket:" + ticket
+}
+
+// CreateTicket issues a short-lived single-use ticket standing for the token
+// of the request, for clients that cannot send headers on the websocket
+// upgrade.
+func (a *API) CreateTicket(ctx *gin.Context) {
+	token := utils.GetToken(ctx)
+	if token == nil {
+		utils.HandleHttpError(utils.UnauthorizedError("claims not found"), ctx)
+		return
+	}
+
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		utils.HandleHttpError(utils.InternalServerError("unable to create a ticket").WithInternalError(err), ctx)
+		return
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(data)
+
+	ttl := a.config.SERVER.TicketTTL
+	if err := a.rdb.Set(ctx, ticketKey(ticket), token.Raw, ttl).Err(); err != nil {
+		utils.HandleHttpError(utils.InternalServerError("unable to create a ticket").WithInternalError(err), ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, TicketResponse{Ticket: ticket, ExpiresIn: int64(ttl.Seconds())})
+}
+
+// redeemTicket returns the token the ticket stands for and invalidates it.
+func (a *API) redeemTicket(ctx *gin.Context, ticket string) (string, *utils.HTTPError) {
+	token, err := a.rdb.GetDel(ctx, ticketKey(ticket)).Result()
+	if err == redis.Nil {
+		return "", utils.UnauthorizedError("invalid or expired ticket")
+	}
+	if err != nil {
+		return "", utils.InternalServerError("unable to verify the ticket").WithInternalError(err)
+	}
+	return token, nil
+}
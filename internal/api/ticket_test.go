@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hiumesh/go-chat-server/internal/conf"
+)
+
+func createTestTicket(t *testing.T, a *API, token string) string {
+	t.Helper()
+
+	recorder := serveTestRequest(a, http.MethodPost, "/tickets", token)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", recorder.Code, http.StatusCreated)
+	}
+
+	var response TicketResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Ticket
+}
+
+func TestTicketsAreSingleUse(t *testing.T) {
+	a := newTestAPI(t, nil)
+	ticket := createTestTicket(t, a, testToken(t, "alice", "authenticated"))
+
+	// The connection is unknown, the request got past authentication.
+	path := "/poll?connection_id=unknown&ticket=" + ticket
+	if recorder := serveTestRequest(a, http.MethodGet, path, ""); recorder.Code != http.StatusNotFound {
+		t.Fatalf("got status %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+	if recorder := serveTestRequest(a, http.MethodGet, path, ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d for a redeemed ticket, want %d", recorder.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestTicketsAndCookiesOnlyAuthenticateStreams(t *testing.T) {
+	a := newTestAPI(t, func(config *conf.GlobalConfiguration) {
+		config.COOKIE.Key = "chat"
+	})
+	token := testToken(t, "alice", "authenticated")
+
+	ticket := createTestTicket(t, a, token)
+	if recorder := serveTestRequest(a, http.MethodGet, "/conversations?ticket="+ticket, ""); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d for a ticket, want %d", recorder.Code, http.StatusUnauthorized)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/poll?connection_id=unknown", http.StatusNotFound},
+		{"/conversations", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, test.path, nil)
+		request.AddCookie(&http.Cookie{Name: "chat-access-token", Value: token})
+
+		recorder := httptest.NewRecorder()
+		a.handler.ServeHTTP(recorder, request)
+		if recorder.Code != test.status {
+			t.Errorf("got status %d for a cookie on %s, want %d", recorder.Code, test.path, test.status)
+		}
+	}
+}
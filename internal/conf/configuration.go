@@ -1,7 +1,11 @@
 package conf
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gocql/gocql"
 	"github.com/hiumesh/go-chat-server/internal/utils"
@@ -11,12 +15,50 @@ import (
 
 type ServerConfiguration struct {
 	Id                   string
-	MaxPerUserConnection string `envconfig:"GO_SOCKET_MAX_PER_USER_CONNECTION" default:"2"`
+	MaxPerUserConnection string        `envconfig:"GO_SOCKET_MAX_PER_USER_CONNECTION" default:"2"`
+	ReplayBufferSize     int64         `envconfig:"GO_SOCKET_REPLAY_BUFFER_SIZE" default:"500"`
+	ReplayBufferTTL      time.Duration `envconfig:"GO_SOCKET_REPLAY_BUFFER_TTL" default:"24h"`
+	PendingDeliveryTTL   time.Duration `envconfig:"GO_SOCKET_PENDING_DELIVERY_TTL" default:"168h"`
+	TokenExpiryWarning   time.Duration `envconfig:"GO_SOCKET_TOKEN_EXPIRY_WARNING" default:"1m"`
+	RevocationTTL        time.Duration `envconfig:"GO_SOCKET_REVOCATION_TTL" default:"24h"`
+	TicketTTL            time.Duration `envconfig:"GO_SOCKET_TICKET_TTL" default:"30s"`
+	EgressQueueSize      int           `envconfig:"GO_SOCKET_EGRESS_QUEUE_SIZE" default:"256"`
+	EgressOverflow       string        `envconfig:"GO_SOCKET_EGRESS_OVERFLOW" default:"drop_oldest"`
+	BatchMaxSize         int           `envconfig:"GO_SOCKET_BATCH_MAX_SIZE" default:"32"`
+	BatchFlushLatency    time.Duration `envconfig:"GO_SOCKET_BATCH_FLUSH_LATENCY" default:"0s"`
+	Compression          bool          `envconfig:"GO_SOCKET_COMPRESSION" default:"true"`
+	CompressionLevel     int           `envconfig:"GO_SOCKET_COMPRESSION_LEVEL" default:"1"`
+	CompressionMinSize   int           `envconfig:"GO_SOCKET_COMPRESSION_MIN_SIZE" default:"512"`
+	PollTimeout          time.Duration `envconfig:"GO_SOCKET_POLL_TIMEOUT" default:"25s"`
+	PollIdleTimeout      time.Duration `envconfig:"GO_SOCKET_POLL_IDLE_TIMEOUT" default:"1m"`
+}
+
+func (c *ServerConfiguration) Validate() error {
+	if c.EgressQueueSize < 1 {
+		return fmt.Errorf("egress queue size must be positive")
+	}
+	if c.BatchMaxSize < 1 {
+		return fmt.Errorf("batch max size must be positive")
+	}
+	// levels of compress/flate, -2 being huffman only
+	if c.CompressionLevel < -2 || c.CompressionLevel > 9 {
+		return fmt.Errorf("compression level must be between -2 and 9")
+	}
+	switch c.EgressOverflow {
+	case "drop_oldest", "drop_newest", "disconnect":
+		return nil
+	default:
+		return fmt.Errorf("unsupported egress overflow policy: %q", c.EgressOverflow)
+	}
 }
 
 type APIConfiguration struct {
 	Host string
 	Port string `envconfig:"GO_SOCKET_PORT" default:"8080"`
+	// PublicMetrics and PublicSchemas serve /metrics and /schemas without a
+	// token, they require the admin role otherwise.
+	PublicMetrics bool `envconfig:"GO_SOCKET_PUBLIC_METRICS" default:"false"`
+	PublicSchemas bool `envconfig:"GO_SOCKET_PUBLIC_SCHEMAS" default:"false"`
 }
 
 func (c *APIConfiguration) Validate() error {
@@ -37,7 +79,19 @@ type CookieConfiguration struct {
 }
 
 type JWTConfiguration struct {
-	Secret string `json:"secret" required:"true"`
+	Secret              string        `json:"secret"`
+	JWKS                string        `envconfig:"GO_SOCKET_JWT_JWKS"`
+	JWKSRefreshInterval time.Duration `envconfig:"GO_SOCKET_JWT_JWKS_REFRESH_INTERVAL" default:"15m"`
+	Audience            []string      `envconfig:"GO_SOCKET_JWT_AUDIENCE"`
+	Issuer              string        `envconfig:"GO_SOCKET_JWT_ISSUER"`
+	AdminRole           string        `envconfig:"GO_SOCKET_JWT_ADMIN_ROLE" default:"service_role"`
+}
+
+func (c *JWTConfiguration) Validate() error {
+	if c.Secret == "" && c.JWKS == "" {
+		return fmt.Errorf("either a jwt secret or a jwks source is required")
+	}
+	return nil
 }
 
 func (c *DBConfiguration) Validate() error {
@@ -52,8 +106,80 @@ func (c *REDISConfiguration) Validate() error {
 	return nil
 }
 
+type BusConfiguration struct {
+	Driver       string `envconfig:"GO_SOCKET_BUS_DRIVER" default:"streams"`
+	StreamMaxLen int64  `envconfig:"GO_SOCKET_BUS_STREAM_MAX_LEN" default:"10000"`
+	// StreamTTL is how long events stay in a stream before being trimmed.
+	StreamTTL time.Duration `envconfig:"GO_SOCKET_BUS_STREAM_TTL" default:"1h"`
+	// Codec encodes the events published on Redis, every node must use the
+	// same one.
+	Codec string `envconfig:"GO_SOCKET_BUS_CODEC" default:"json"`
+}
+
+func (c *BusConfiguration) Validate() error {
+	switch c.Driver {
+	case "streams", "pubsub", "memory":
+	default:
+		return fmt.Errorf("unsupported bus driver: %q", c.Driver)
+	}
+
+	switch c.Codec {
+	case "json", "msgpack", "proto":
+		return nil
+	default:
+		return fmt.Errorf("unsupported bus codec: %q", c.Codec)
+	}
+}
+
 type CORSConfiguration struct {
 	AllowedHeaders []string `json:"allowed_headers" split_words:"true"`
+	AllowedOrigins []string `json:"allowed_origins" split_words:"true" default:"http://localhost:8080"`
+}
+
+func (c *CORSConfiguration) Validate() error {
+	for _, pattern := range c.AllowedOrigins {
+		if pattern == "*" {
+			continue
+		}
+		if _, err := url.Parse(pattern); err != nil {
+			return fmt.Errorf("invalid allowed origin %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// AllowsOrigin matches the origin against the allowed origins. Entries are
+// either "*", a full origin such as "https://chat.example.com", a host
+// allowed with any scheme such as "chat.example.com", or a wildcard for the
+// subdomains of a host such as "https://*.example.com".
+func (c *CORSConfiguration) AllowsOrigin(origin string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+
+	for _, pattern := range c.AllowedOrigins {
+		if pattern == "*" {
+			return true
+		}
+
+		scheme, host, ok := strings.Cut(pattern, "://")
+		if !ok {
+			scheme, host = "", pattern
+		}
+		if scheme != "" && !strings.EqualFold(scheme, parsed.Scheme) {
+			continue
+		}
+
+		if suffix, ok := strings.CutPrefix(host, "*."); ok {
+			if strings.HasSuffix(strings.ToLower(parsed.Host), "."+strings.ToLower(suffix)) {
+				return true
+			}
+		} else if strings.EqualFold(host, parsed.Host) {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *CORSConfiguration) AllAllowedHeaders(defaults []string) []string {
@@ -81,10 +207,12 @@ type GlobalConfiguration struct {
 	API     APIConfiguration
 	DB      DBConfiguration
 	REDIS   REDISConfiguration
+	BUS     BusConfiguration
 	CORS    CORSConfiguration   `json:"cors"`
 	JWT     JWTConfiguration    `json:"jwt"`
 	COOKIE  CookieConfiguration `json:"cookies"`
 	LOGGING LoggingConfig       `envconfig:"LOG"`
+	TRACING TracingConfig
 }
 
 func loadEnvironment(filename string) error {
@@ -122,9 +250,14 @@ func (c *GlobalConfiguration) Validate() error {
 	validatables := []interface {
 		Validate() error
 	}{
+		&c.SERVER,
 		&c.API,
 		&c.DB,
 		&c.REDIS,
+		&c.BUS,
+		&c.JWT,
+		&c.CORS,
+		&c.TRACING,
 	}
 
 	for _, validatable := range validatables {
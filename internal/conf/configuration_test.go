@@ -0,0 +1,45 @@
+package conf
+
+import "testing"
+
+func TestAllowsOrigin(t *testing.T) {
+	config := CORSConfiguration{AllowedOrigins: []string{
+		"https://chat.example.com",
+		"localhost:3000",
+		"https://*.example.org",
+	}}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://chat.example.com", true},
+		{"HTTPS://Chat.Example.com", true},
+		{"http://chat.example.com", false},
+		{"https://chat.example.com:8443", false},
+		{"http://localhost:3000", true},
+		{"https://localhost:3000", true},
+		{"http://localhost:3001", false},
+		{"https://app.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"http://app.example.org", false},
+		{"https://evilexample.org", false},
+		{"null", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if allowed := config.AllowsOrigin(test.origin); allowed != test.allowed {
+			t.Errorf("got %v for %q, want %v", allowed, test.origin, test.allowed)
+		}
+	}
+
+	wildcard := CORSConfiguration{AllowedOrigins: []string{"*"}}
+	if !wildcard.AllowsOrigin("https://anywhere.test") {
+		t.Error("wildcard rejected an origin")
+	}
+	if wildcard.AllowsOrigin("null") {
+		t.Error("wildcard accepted an origin without a host")
+	}
+}
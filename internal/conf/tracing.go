@@ -0,0 +1,19 @@
+package conf
+
+import "fmt"
+
+type TracingConfig struct {
+	Exporter    string `envconfig:"GO_SOCKET_TRACING_EXPORTER"`
+	Endpoint    string `envconfig:"GO_SOCKET_TRACING_ENDPOINT"`
+	Insecure    bool   `envconfig:"GO_SOCKET_TRACING_INSECURE"`
+	ServiceName string `envconfig:"GO_SOCKET_TRACING_SERVICE_NAME" default:"gosocket"`
+}
+
+func (c *TracingConfig) Validate() error {
+	switch c.Exporter {
+	case "", "otlp", "stdout":
+		return nil
+	default:
+		return fmt.Errorf("unsupported tracing exporter: %q", c.Exporter)
+	}
+}
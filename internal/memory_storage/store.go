@@ -0,0 +1,414 @@
+package memory_storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+// Store keeps the chat data in process memory. It implements models.Store
+// for standalone mode and tests, everything is lost on restart.
+type Store struct {
+	messages          map[string]*models.Message
+	conversations     map[string][]string
+	threads           map[string][]string
+	edits             map[string][]models.MessageEdit
+	reactions         map[string]map[string]map[string]bool
+	channelUsers      map[string]map[string]bool
+	userConversations map[string]map[string]*models.UserConversation
+	lastSeen          map[string]time.Time
+	sync.RWMutex
+}
+
+func NewStore() *Store {
+	return &Store{
+		messages:          make(map[string]*models.Message),
+		conversations:     make(map[string][]string),
+		threads:           make(map[string][]string),
+		edits:             make(map[string][]models.MessageEdit),
+		reactions:         make(map[string]map[string]map[string]bool),
+		channelUsers:      make(map[string]map[string]bool),
+		userConversations: make(map[string]map[string]*models.UserConversation),
+		lastSeen:          make(map[string]time.Time),
+	}
+}
+
+// AddChannelUser adds the user to the members of the channel. Membership is
+// managed outside of the chat server, this lets standalone setups seed it.
+func (s *Store) AddChannelUser(channelId string, userId string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.channelUsers[channelId] == nil {
+		s.channelUsers[channelId] = make(map[string]bool)
+	}
+	s.channelUsers[channelId][userId] = true
+}
+
+// LoadChannelUsers adds the members listed in a JSON file mapping channel ids
+// to user ids.
+func (s *Store) LoadChannelUsers(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var channels map[string][]string
+	if err := json.Unmarshal(data, &channels); err != nil {
+		return fmt.Errorf("bad channel members file: %v", err)
+	}
+
+	for channelId, userIds := range channels {
+		for _, userId := range userIds {
+			s.AddChannelUser(channelId, userId)
+		}
+	}
+	return nil
+}
+
+func (s *Store) InsertMessage(message *models.Message) error {
+	s.Lock()
+	defer s.Unlock()
+
+	id := gocql.TimeUUID()
+	message.Id = id.String()
+	message.CreatedAt = id.Time()
+	message.Bucket = models.MessageBucket(message.CreatedAt)
+
+	stored := *message
+	s.messages[message.Id] = &stored
+
+	if message.ThreadRootId != "" {
+		s.threads[message.ThreadRootId] = append(s.threads[message.ThreadRootId], message.Id)
+	} else {
+		s.conversations[message.ConversationId] = append(s.conversations[message.ConversationId], message.Id)
+	}
+	return nil
+}
+
+func (s *Store) GetMessage(id string) (*models.Message, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	stored, ok := s.messages[id]
+	if !ok {
+		return nil, models.ErrMessageNotFound
+	}
+
+	message := *stored
+	return &message, nil
+}
+
+func (s *Store) EditMessage(message *models.Message, body string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	stored, ok := s.messages[message.Id]
+	if !ok {
+		return models.ErrMessageNotFound
+	}
+
+	editId := gocql.TimeUUID()
+	edit := models.MessageEdit{
+		MessageId: message.Id,
+		Id:        editId.String(),
+		UserId:    stored.UserId,
+		Body:      stored.Body,
+		EditedAt:  editId.Time(),
+	}
+	s.edits[message.Id] = append([]models.MessageEdit{edit}, s.edits[message.Id]...)
+
+	message.Body = body
+	message.UpdatedAt = &edit.EditedAt
+	stored.Body = message.Body
+	stored.UpdatedAt = message.UpdatedAt
+	return nil
+}
+
+func (s *Store) DeleteMessage(message *models.Message) error {
+	s.Lock()
+	defer s.Unlock()
+
+	stored, ok := s.messages[message.Id]
+	if !ok {
+		return models.ErrMessageNotFound
+	}
+
+	deletedAt := time.Now()
+	message.Body = ""
+	message.DeletedAt = &deletedAt
+	stored.Body = message.Body
+	stored.DeletedAt = message.DeletedAt
+	return nil
+}
+
+func (s *Store) ListMessageEdits(messageId string) ([]models.MessageEdit, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return append([]models.MessageEdit(nil), s.edits[messageId]...), nil
+}
+
+func (s *Store) ListConversationMessages(conversationId string, cursor string, limit int) ([]models.Message, string, error) {
+	_, beforeId, err := models.DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	ids := s.conversations[conversationId]
+	messages := make([]models.Message, 0, limit)
+	nextCursor := ""
+	for i := len(ids) - 1; i >= 0; i-- {
+		if beforeId != "" && !models.MessageTime(ids[i]).Before(models.MessageTime(beforeId)) {
+			continue
+		}
+		if len(messages) == limit {
+			last := messages[limit-1]
+			nextCursor = models.EncodeCursor(last.Bucket, last.Id)
+			break
+		}
+		messages = append(messages, s.messageWithReactions(ids[i]))
+	}
+
+	return messages, nextCursor, nil
+}
+
+func (s *Store) UpdateThreadSummary(root *models.Message, reply *models.Message) error {
+	s.Lock()
+	defer s.Unlock()
+
+	stored, ok := s.messages[root.Id]
+	if !ok {
+		return models.ErrMessageNotFound
+	}
+
+	if reply.DeletedAt == nil {
+		stored.ReplyCount++
+		if stored.LastReplyAt == nil || reply.CreatedAt.After(*stored.LastReplyAt) {
+			lastReplyAt := reply.CreatedAt
+			stored.LastReplyAt = &lastReplyAt
+		}
+	} else {
+		stored.ReplyCount--
+		if stored.LastReplyAt != nil && !reply.CreatedAt.Before(*stored.LastReplyAt) {
+			stored.LastReplyAt = s.lastReplyAt(root.Id)
+		}
+	}
+
+	root.ReplyCount = stored.ReplyCount
+	root.LastReplyAt = stored.LastReplyAt
+	return nil
+}
+
+// lastReplyAt returns when the newest reply of the thread that is not
+// deleted was sent. The caller must hold the lock.
+func (s *Store) lastReplyAt(threadRootId string) *time.Time {
+	replyIds := s.threads[threadRootId]
+	for i := len(replyIds) - 1; i >= 0; i-- {
+		if reply := s.messages[replyIds[i]]; reply.DeletedAt == nil {
+			lastReplyAt := reply.CreatedAt
+			return &lastReplyAt
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListThreadMessages(root *models.Message, cursor string, limit int) ([]models.Message, string, error) {
+	_, afterId, err := models.DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	messages := make([]models.Message, 0, limit)
+	nextCursor := ""
+	for _, id := range s.threads[root.Id] {
+		if afterId != "" && !models.MessageTime(id).After(models.MessageTime(afterId)) {
+			continue
+		}
+		if len(messages) == limit {
+			last := messages[limit-1]
+			nextCursor = models.EncodeCursor(last.Bucket, last.Id)
+			break
+		}
+		messages = append(messages, s.messageWithReactions(id))
+	}
+
+	return messages, nextCursor, nil
+}
+
+// messageWithReactions copies a stored message along with its aggregated
+// reactions. The caller must hold the lock.
+func (s *Store) messageWithReactions(id string) models.Message {
+	message := *s.messages[id]
+	message.Reactions = nil
+	for emoji, userIds := range s.reactions[id] {
+		if len(userIds) > 0 {
+			message.Reactions = append(message.Reactions, models.ReactionCount{Emoji: emoji, Count: len(userIds)})
+		}
+	}
+	sort.Slice(message.Reactions, func(a, b int) bool {
+		return message.Reactions[a].Emoji < message.Reactions[b].Emoji
+	})
+	return message
+}
+
+func (s *Store) AddReaction(message *models.Message, emoji string, userId string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.reactions[message.Id] == nil {
+		s.reactions[message.Id] = make(map[string]map[string]bool)
+	}
+	if s.reactions[message.Id][emoji] == nil {
+		s.reactions[message.Id][emoji] = make(map[string]bool)
+	}
+	s.reactions[message.Id][emoji][userId] = true
+	return nil
+}
+
+func (s *Store) RemoveReaction(message *models.Message, emoji string, userId string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.reactions[message.Id][emoji], userId)
+	return nil
+}
+
+func (s *Store) CountReaction(message *models.Message, emoji string) (int, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return len(s.reactions[message.Id][emoji]), nil
+}
+
+func (s *Store) GetChannelUserIds(channelId string) ([]string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	userIds := make([]string, 0, len(s.channelUsers[channelId]))
+	for userId := range s.channelUsers[channelId] {
+		userIds = append(userIds, userId)
+	}
+	sort.Strings(userIds)
+	return userIds, nil
+}
+
+func (s *Store) IsChannelUser(channelId string, userId string) (bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.channelUsers[channelId][userId], nil
+}
+
+func (s *Store) RecordConversationMessage(message *models.Message, participantIds []string) error {
+	s.Lock()
+	for _, participantId := range participantIds {
+		conversation := s.userConversation(participantId, message.ConversationId)
+		conversation.LastMessageAt = message.CreatedAt
+		if message.ChannelId != "" {
+			conversation.ChannelId = message.ChannelId
+		} else {
+			conversation.PeerId = models.DirectPeerId(participantIds, participantId)
+		}
+	}
+	s.Unlock()
+
+	_, err := s.MarkConversationRead(message.UserId, message.ConversationId, message.Id)
+	return err
+}
+
+func (s *Store) MarkConversationRead(userId string, conversationId string, messageId string) (bool, error) {
+	if _, err := gocql.ParseUUID(messageId); err != nil {
+		return false, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	conversation := s.userConversation(userId, conversationId)
+	if conversation.LastReadId != "" && !models.MessageTime(conversation.LastReadId).Before(models.MessageTime(messageId)) {
+		return false, nil
+	}
+
+	conversation.LastReadId = messageId
+	conversation.LastReadAt = time.Now()
+	return true, nil
+}
+
+// userConversation returns the conversation entry of the user, creating it
+// when missing. The caller must hold the write lock.
+func (s *Store) userConversation(userId string, conversationId string) *models.UserConversation {
+	if s.userConversations[userId] == nil {
+		s.userConversations[userId] = make(map[string]*models.UserConversation)
+	}
+
+	conversation, ok := s.userConversations[userId][conversationId]
+	if !ok {
+		conversation = &models.UserConversation{UserId: userId, ConversationId: conversationId}
+		s.userConversations[userId][conversationId] = conversation
+	}
+	return conversation
+}
+
+func (s *Store) GetUserConversation(userId string, conversationId string) (*models.UserConversation, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	stored, ok := s.userConversations[userId][conversationId]
+	if !ok {
+		return nil, nil
+	}
+
+	conversation := *stored
+	return &conversation, nil
+}
+
+func (s *Store) ListUserConversations(userId string) ([]models.UserConversation, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	conversations := make([]models.UserConversation, 0, len(s.userConversations[userId]))
+	for _, stored := range s.userConversations[userId] {
+		conversation := *stored
+		for _, id := range s.conversations[conversation.ConversationId] {
+			if conversation.LastReadId == "" || models.MessageTime(id).After(models.MessageTime(conversation.LastReadId)) {
+				conversation.Unread++
+			}
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	sort.Slice(conversations, func(a, b int) bool {
+		return conversations[a].ConversationId < conversations[b].ConversationId
+	})
+	return conversations, nil
+}
+
+func (s *Store) UpdateLastSeen(userId string, lastSeenAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastSeen[userId] = lastSeenAt
+	return nil
+}
+
+func (s *Store) GetLastSeen(userId string) (time.Time, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.lastSeen[userId], nil
+}
+
+var _ models.Store = (*Store)(nil)
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/scylladb/gocqlx/qb"
+	"github.com/scylladb/gocqlx/table"
+)
+
+var channelUserMetaData = table.Metadata{
+	Name:    "channel_users",
+	Columns: []string{"channel_id", "user_id"},
+	PartKey: []string{"channel_id"},
+	SortKey: []string{"user_id"},
+}
+
+var channelUserTable = table.New(channelUserMetaData)
+
+type ChannelUser struct {
+	ChannelId string
+	UserId    string
+}
+
+func (s *ScyllaStore) GetChannelUserIds(channelId string) ([]string, error) {
+	var channelUsers []ChannelUser
+	q := s.db.Query(channelUserTable.Select()).BindMap(qb.M{"channel_id": channelId})
+	if err := q.SelectRelease(&channelUsers); err != nil {
+		return nil, err
+	}
+
+	userIds := make([]string, 0, len(channelUsers))
+	for _, channelUser := range channelUsers {
+		userIds = append(userIds, channelUser.UserId)
+	}
+	return userIds, nil
+}
+
+func (s *ScyllaStore) IsChannelUser(channelId string, userId string) (bool, error) {
+	var channelUsers []ChannelUser
+	q := s.db.Query(channelUserTable.Get()).BindStruct(ChannelUser{ChannelId: channelId, UserId: userId})
+	if err := q.SelectRelease(&channelUsers); err != nil {
+		return false, err
+	}
+	return len(channelUsers) > 0, nil
+}
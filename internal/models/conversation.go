@@ -0,0 +1,203 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/scylladb/gocqlx/qb"
+	"github.com/scylladb/gocqlx/table"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+var userConversationMetaData = table.Metadata{
+	Name:    "user_conversations",
+	Columns: []string{"user_id", "conversation_id", "channel_id", "peer_id", "last_read_id", "last_read_at", "last_message_at"},
+	PartKey: []string{"user_id"},
+	SortKey: []string{"conversation_id"},
+}
+
+var userConversationTable = table.New(userConversationMetaData)
+
+var conversationUnreadMetaData = table.Metadata{
+	Name:    "conversation_unread",
+	Columns: []string{"user_id", "conversation_id", "unread"},
+	PartKey: []string{"user_id"},
+	SortKey: []string{"conversation_id"},
+}
+
+var conversationUnreadTable = table.New(conversationUnreadMetaData)
+
+type UserConversation struct {
+	UserId         string    `json:"-"`
+	ConversationId string    `json:"conversation_id"`
+	ChannelId      string    `json:"channel_id,omitempty"`
+	PeerId         string    `json:"peer_id,omitempty"`
+	LastReadId     string    `json:"last_read_id,omitempty"`
+	LastReadAt     time.Time `json:"last_read_at"`
+	LastMessageAt  time.Time `json:"last_message_at"`
+	Unread         int64     `json:"unread" db:"-"`
+}
+
+type ConversationUnread struct {
+	UserId         string
+	ConversationId string
+	Unread         int64
+}
+
+// RecordConversationMessage updates the conversation list of every
+// participant after a new message and increments the unread counter of
+// everyone but the sender, whose read position moves to the new message.
+func (s *ScyllaStore) RecordConversationMessage(message *Message, participantIds []string) error {
+	seen := make(map[string]bool)
+	for _, participantId := range participantIds {
+		if seen[participantId] {
+			continue
+		}
+		seen[participantId] = true
+
+		conversation := UserConversation{
+			UserId:         participantId,
+			ConversationId: message.ConversationId,
+			ChannelId:      message.ChannelId,
+			LastMessageAt:  message.CreatedAt,
+		}
+
+		columns := []string{"last_message_at"}
+		if message.ChannelId != "" {
+			columns = append(columns, "channel_id")
+		} else {
+			conversation.PeerId = DirectPeerId(participantIds, participantId)
+			columns = append(columns, "peer_id")
+		}
+
+		q := s.db.Query(userConversationTable.Update(columns...)).BindStruct(conversation)
+		if err := q.ExecRelease(); err != nil {
+			return err
+		}
+
+		if participantId == message.UserId {
+			if _, err := s.MarkConversationRead(participantId, message.ConversationId, message.Id); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := addConversationUnread(s.db, participantId, message.ConversationId, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkConversationRead moves the user's read position forward to messageId
+// and resets the unread counter. It reports false when the position was
+// already at or past the message.
+func (s *ScyllaStore) MarkConversationRead(userId string, conversationId string, messageId string) (bool, error) {
+	readId, err := gocql.ParseUUID(messageId)
+	if err != nil {
+		return false, err
+	}
+
+	var conversations []UserConversation
+	q := s.db.Query(userConversationTable.Get()).BindStruct(UserConversation{UserId: userId, ConversationId: conversationId})
+	if err := q.SelectRelease(&conversations); err != nil {
+		return false, err
+	}
+
+	if len(conversations) > 0 && conversations[0].LastReadId != "" {
+		lastReadId, err := gocql.ParseUUID(conversations[0].LastReadId)
+		if err == nil && !lastReadId.Time().Before(readId.Time()) {
+			return false, nil
+		}
+	}
+
+	conversation := UserConversation{
+		UserId:         userId,
+		ConversationId: conversationId,
+		LastReadId:     messageId,
+		LastReadAt:     time.Now(),
+	}
+	q = s.db.Query(userConversationTable.Update("last_read_id", "last_read_at")).BindStruct(conversation)
+	if err := q.ExecRelease(); err != nil {
+		return false, err
+	}
+
+	if err := resetConversationUnread(s.db, userId, conversationId); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetUserConversation returns nil when the user is not part of the
+// conversation.
+func (s *ScyllaStore) GetUserConversation(userId string, conversationId string) (*UserConversation, error) {
+	var conversations []UserConversation
+	q := s.db.Query(userConversationTable.Get()).BindStruct(UserConversation{UserId: userId, ConversationId: conversationId})
+	if err := q.SelectRelease(&conversations); err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, nil
+	}
+	return &conversations[0], nil
+}
+
+// ListUserConversations returns the conversations of the user along with
+// their unread counters.
+func (s *ScyllaStore) ListUserConversations(userId string) ([]UserConversation, error) {
+	var conversations []UserConversation
+	q := s.db.Query(userConversationTable.Select()).BindMap(qb.M{"user_id": userId})
+	if err := q.SelectRelease(&conversations); err != nil {
+		return nil, err
+	}
+
+	var unread []ConversationUnread
+	q = s.db.Query(conversationUnreadTable.Select()).BindMap(qb.M{"user_id": userId})
+	if err := q.SelectRelease(&unread); err != nil {
+		return nil, err
+	}
+
+	unreadByConversation := make(map[string]int64, len(unread))
+	for _, u := range unread {
+		unreadByConversation[u.ConversationId] = u.Unread
+	}
+
+	for i := range conversations {
+		conversations[i].Unread = unreadByConversation[conversations[i].ConversationId]
+	}
+
+	return conversations, nil
+}
+
+// DirectPeerId returns the other participant of a direct conversation, the
+// user themselves when they message their own account.
+func DirectPeerId(participantIds []string, userId string) string {
+	for _, participantId := range participantIds {
+		if participantId != userId {
+			return participantId
+		}
+	}
+	return userId
+}
+
+func addConversationUnread(db gocqlx.Session, userId string, conversationId string, delta int64) error {
+	stmt, names := qb.Update(conversationUnreadMetaData.Name).Add("unread").Where(qb.Eq("user_id"), qb.Eq("conversation_id")).ToCql()
+	q := db.Query(stmt, names).BindStruct(ConversationUnread{UserId: userId, ConversationId: conversationId, Unread: delta})
+	return q.ExecRelease()
+}
+
+// resetConversationUnread subtracts the value read from the counter. Counter
+// updates commute, so messages counted meanwhile stay unread.
+func resetConversationUnread(db gocqlx.Session, userId string, conversationId string) error {
+	var unread []ConversationUnread
+	q := db.Query(conversationUnreadTable.Get()).BindStruct(ConversationUnread{UserId: userId, ConversationId: conversationId})
+	if err := q.SelectRelease(&unread); err != nil {
+		return err
+	}
+
+	if len(unread) == 0 || unread[0].Unread == 0 {
+		return nil
+	}
+	return addConversationUnread(db, userId, conversationId, -unread[0].Unread)
+}
@@ -1,29 +1,438 @@
 package models
 
 import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
 	"github.com/google/uuid"
+	"github.com/scylladb/gocqlx/qb"
 	"github.com/scylladb/gocqlx/table"
 	"github.com/scylladb/gocqlx/v2"
 )
 
 var messageMetaData = table.Metadata{
 	Name:    "messages",
-	Columns: []string{"id", "user_id", "body"},
+	Columns: []string{"id", "conversation_id", "channel_id", "thread_root_id", "user_id", "body", "created_at"},
+	PartKey: []string{"id"},
 }
 
 var messageTable = table.New(messageMetaData)
 
+var conversationMessageMetaData = table.Metadata{
+	Name:    "conversation_messages",
+	Columns: []string{"conversation_id", "bucket", "id", "channel_id", "user_id", "body", "created_at"},
+	PartKey: []string{"conversation_id", "bucket"},
+	SortKey: []string{"id"},
+}
+
+var conversationMessageTable = table.New(conversationMessageMetaData)
+
+var threadMessageMetaData = table.Metadata{
+	Name:    "thread_messages",
+	Columns: []string{"thread_root_id", "id", "conversation_id", "channel_id", "user_id", "body", "created_at"},
+	PartKey: []string{"thread_root_id"},
+	SortKey: []string{"id"},
+}
+
+var threadMessageTable = table.New(threadMessageMetaData)
+
+var threadReplyCountMetaData = table.Metadata{
+	Name:    "thread_reply_counts",
+	Columns: []string{"thread_root_id", "replies"},
+	PartKey: []string{"thread_root_id"},
+}
+
+var threadReplyCountTable = table.New(threadReplyCountMetaData)
+
+var conversationBucketMetaData = table.Metadata{
+	Name:    "conversation_buckets",
+	Columns: []string{"conversation_id", "bucket"},
+	PartKey: []string{"conversation_id"},
+	SortKey: []string{"bucket"},
+}
+
+var conversationBucketTable = table.New(conversationBucketMetaData)
+
+var directConversationNamespace = uuid.MustParse("5b0f8a43-3c1e-4c5e-9a43-0d2f7c1b6e21")
+
+var messageEditMetaData = table.Metadata{
+	Name:    "message_edits",
+	Columns: []string{"message_id", "id", "user_id", "body", "edited_at"},
+	PartKey: []string{"message_id"},
+	SortKey: []string{"id"},
+}
+
+var messageEditTable = table.New(messageEditMetaData)
+
+var ErrInvalidCursor = errors.New("invalid history cursor")
+var ErrMessageNotFound = errors.New("message not found")
+var ErrInvalidThreadRoot = errors.New("thread root must be a message of the same conversation that is not a reply")
+
 type Message struct {
-	Id     string
-	UserId string
-	Body   string
+	Id             string          `json:"id"`
+	ConversationId string          `json:"conversation_id"`
+	ChannelId      string          `json:"channel_id,omitempty"`
+	ThreadRootId   string          `json:"thread_root_id,omitempty"`
+	UserId         string          `json:"user_id"`
+	Body           string          `json:"body"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      *time.Time      `json:"updated_at,omitempty"`
+	DeletedAt      *time.Time      `json:"deleted_at,omitempty"`
+	ReplyCount     int             `json:"reply_count,omitempty"`
+	LastReplyAt    *time.Time      `json:"last_reply_at,omitempty"`
+	Reactions      []ReactionCount `json:"reactions,omitempty" db:"-"`
+	Bucket         int             `json:"-"`
+}
+
+// MessageEdit keeps the body a message had before it was edited.
+type MessageEdit struct {
+	MessageId string    `json:"message_id"`
+	Id        string    `json:"id"`
+	UserId    string    `json:"user_id"`
+	Body      string    `json:"body"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// DirectConversationId derives a stable conversation id shared by both
+// participants of a direct conversation.
+func DirectConversationId(userId string, otherUserId string) string {
+	userIds := []string{userId, otherUserId}
+	sort.Strings(userIds)
+	return uuid.NewSHA1(directConversationNamespace, []byte(strings.Join(userIds, ":"))).String()
 }
 
-func InsertMessage(db gocqlx.Session, message *Message) error {
-	message.Id = uuid.NewString()
-	q := db.Query(messageTable.Insert()).BindStruct(message)
+// MessageBucket returns the monthly partition bucket a message created at t
+// belongs to, e.g. 202401.
+func MessageBucket(t time.Time) int {
+	t = t.UTC()
+	return t.Year()*100 + int(t.Month())
+}
+
+// InsertMessage stores a new message. Replies are kept in their thread only
+// and refresh the reply summary of the thread root instead of showing up in
+// the conversation history.
+func (s *ScyllaStore) InsertMessage(message *Message) error {
+	id := gocql.TimeUUID()
+	message.Id = id.String()
+	message.CreatedAt = id.Time()
+	message.Bucket = MessageBucket(message.CreatedAt)
+
+	columns := messageColumns(message, "id", "conversation_id", "user_id", "body", "created_at")
+	if message.ThreadRootId != "" {
+		columns = append(columns, "thread_root_id")
+	}
+
+	stmt, names := qb.Insert(messageMetaData.Name).Columns(columns...).ToCql()
+	q := s.db.Query(stmt, names).BindStruct(message)
+	if err := q.ExecRelease(); err != nil {
+		return err
+	}
+
+	if message.ThreadRootId != "" {
+		stmt, names = qb.Insert(threadMessageMetaData.Name).Columns(messageColumns(message, "thread_root_id", "id", "conversation_id", "user_id", "body", "created_at")...).ToCql()
+		q = s.db.Query(stmt, names).BindStruct(message)
+		return q.ExecRelease()
+	}
+
+	stmt, names = qb.Insert(conversationMessageMetaData.Name).Columns(messageColumns(message, "conversation_id", "bucket", "id", "user_id", "body", "created_at")...).ToCql()
+	q = s.db.Query(stmt, names).BindStruct(message)
+	if err := q.ExecRelease(); err != nil {
+		return err
+	}
+
+	q = s.db.Query(conversationBucketTable.Insert()).BindStruct(message)
 	if err := q.ExecRelease(); err != nil {
 		return err
 	}
 	return nil
 }
+
+// messageColumns appends channel_id when the message belongs to a channel,
+// leaving it unset for direct messages rather than writing a null.
+func messageColumns(message *Message, columns ...string) []string {
+	if message.ChannelId != "" {
+		columns = append(columns, "channel_id")
+	}
+	return columns
+}
+
+func (s *ScyllaStore) GetMessage(id string) (*Message, error) {
+	messageId, err := gocql.ParseUUID(id)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+
+	var messages []Message
+	q := s.db.Query(messageTable.Get()).BindStruct(Message{Id: id})
+	if err := q.SelectRelease(&messages); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrMessageNotFound
+	}
+
+	message := messages[0]
+	message.Bucket = MessageBucket(messageId.Time())
+	return &message, nil
+}
+
+// EditMessage replaces the body of the message, keeping the previous body in
+// the edit history.
+func (s *ScyllaStore) EditMessage(message *Message, body string) error {
+	editId := gocql.TimeUUID()
+	edit := MessageEdit{
+		MessageId: message.Id,
+		Id:        editId.String(),
+		UserId:    message.UserId,
+		Body:      message.Body,
+		EditedAt:  editId.Time(),
+	}
+
+	q := s.db.Query(messageEditTable.Insert()).BindStruct(edit)
+	if err := q.ExecRelease(); err != nil {
+		return err
+	}
+
+	message.Body = body
+	message.UpdatedAt = &edit.EditedAt
+	return updateMessage(s.db, message, "body", "updated_at")
+}
+
+// DeleteMessage turns the message into a tombstone, clearing its body but
+// keeping its position in the conversation.
+func (s *ScyllaStore) DeleteMessage(message *Message) error {
+	deletedAt := time.Now()
+	message.Body = ""
+	message.DeletedAt = &deletedAt
+	return updateMessage(s.db, message, "body", "deleted_at")
+}
+
+func (s *ScyllaStore) ListMessageEdits(messageId string) ([]MessageEdit, error) {
+	var edits []MessageEdit
+	q := s.db.Query(messageEditTable.Select()).BindMap(qb.M{"message_id": messageId})
+	if err := q.SelectRelease(&edits); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+func updateMessage(db gocqlx.Session, message *Message, columns ...string) error {
+	q := db.Query(messageTable.Update(columns...)).BindStruct(message)
+	if err := q.ExecRelease(); err != nil {
+		return err
+	}
+
+	if message.ThreadRootId != "" {
+		q = db.Query(threadMessageTable.Update(columns...)).BindStruct(message)
+		return q.ExecRelease()
+	}
+
+	q = db.Query(conversationMessageTable.Update(columns...)).BindStruct(message)
+	return q.ExecRelease()
+}
+
+type threadReplyCount struct {
+	ThreadRootId string
+	Replies      int64
+}
+
+// UpdateThreadSummary applies a new or deleted reply to the reply count and
+// last reply timestamp of its thread root. Deleted replies are not counted,
+// the count is kept in a counter so concurrent replies are all counted.
+func (s *ScyllaStore) UpdateThreadSummary(root *Message, reply *Message) error {
+	delta := int64(1)
+	if reply.DeletedAt != nil {
+		delta = -1
+	}
+
+	stmt, names := qb.Update(threadReplyCountMetaData.Name).Add("replies").Where(qb.Eq("thread_root_id")).ToCql()
+	q := s.db.Query(stmt, names).BindStruct(threadReplyCount{ThreadRootId: root.Id, Replies: delta})
+	if err := q.ExecRelease(); err != nil {
+		return err
+	}
+
+	var counts []threadReplyCount
+	q = s.db.Query(threadReplyCountTable.Get()).BindStruct(threadReplyCount{ThreadRootId: root.Id})
+	if err := q.SelectRelease(&counts); err != nil {
+		return err
+	}
+	root.ReplyCount = 0
+	if len(counts) > 0 {
+		root.ReplyCount = int(counts[0].Replies)
+	}
+
+	switch {
+	case reply.DeletedAt == nil:
+		if root.LastReplyAt == nil || reply.CreatedAt.After(*root.LastReplyAt) {
+			lastReplyAt := reply.CreatedAt
+			root.LastReplyAt = &lastReplyAt
+		}
+	case root.LastReplyAt != nil && !reply.CreatedAt.Before(*root.LastReplyAt):
+		lastReplyAt, err := s.lastReplyAt(root)
+		if err != nil {
+			return err
+		}
+		root.LastReplyAt = lastReplyAt
+	}
+	return updateMessage(s.db, root, "reply_count", "last_reply_at")
+}
+
+// lastReplyAt returns when the newest reply of the thread that is not
+// deleted was sent, nil when there is none.
+func (s *ScyllaStore) lastReplyAt(root *Message) (*time.Time, error) {
+	stmt, names := threadMessageTable.SelectBuilder("created_at", "deleted_at").OrderBy("id", qb.DESC).ToCql()
+	iter := s.db.Query(stmt, names).BindMap(qb.M{"thread_root_id": root.Id}).PageSize(100).Iter()
+
+	var reply Message
+	for iter.StructScan(&reply) {
+		if reply.DeletedAt == nil {
+			createdAt := reply.CreatedAt
+			return &createdAt, iter.Close()
+		}
+		reply = Message{}
+	}
+	return nil, iter.Close()
+}
+
+// ListThreadMessages returns up to limit replies of the thread oldest-first,
+// starting after the given cursor.
+func (s *ScyllaStore) ListThreadMessages(root *Message, cursor string, limit int) ([]Message, string, error) {
+	_, afterId, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	messageQuery := threadMessageTable.SelectBuilder().Limit(uint(limit + 1))
+	messageArgs := qb.M{"thread_root_id": root.Id}
+	if afterId != "" {
+		messageQuery = messageQuery.Where(qb.Gt("id"))
+		messageArgs["id"] = afterId
+	}
+
+	var messages []Message
+	q := s.db.Query(messageQuery.ToCql()).BindMap(messageArgs)
+	if err := q.SelectRelease(&messages); err != nil {
+		return nil, "", err
+	}
+
+	for i := range messages {
+		messages[i].Bucket = MessageBucket(MessageTime(messages[i].Id))
+	}
+
+	nextCursor := ""
+	if len(messages) > limit {
+		messages = messages[:limit]
+		last := messages[limit-1]
+		nextCursor = EncodeCursor(last.Bucket, last.Id)
+	}
+
+	if err := attachReactions(s.db, root.ConversationId, messages); err != nil {
+		return nil, "", err
+	}
+
+	return messages, nextCursor, nil
+}
+
+// ListConversationMessages returns up to limit messages of the conversation
+// newest-first, starting after the given cursor. The returned cursor is empty
+// when there are no older messages.
+func (s *ScyllaStore) ListConversationMessages(conversationId string, cursor string, limit int) ([]Message, string, error) {
+	fromBucket, beforeId, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	bucketQuery := conversationBucketTable.SelectBuilder("bucket")
+	bucketArgs := qb.M{"conversation_id": conversationId}
+	if fromBucket != 0 {
+		bucketQuery = bucketQuery.Where(qb.LtOrEq("bucket"))
+		bucketArgs["bucket"] = fromBucket
+	}
+
+	var buckets []int
+	q := s.db.Query(bucketQuery.ToCql()).BindMap(bucketArgs)
+	if err := q.SelectRelease(&buckets); err != nil {
+		return nil, "", err
+	}
+
+	messages := make([]Message, 0, limit+1)
+	for _, bucket := range buckets {
+		messageQuery := conversationMessageTable.SelectBuilder().Limit(uint(limit + 1 - len(messages)))
+		messageArgs := qb.M{"conversation_id": conversationId, "bucket": bucket}
+		if bucket == fromBucket && beforeId != "" {
+			messageQuery = messageQuery.Where(qb.Lt("id"))
+			messageArgs["id"] = beforeId
+		}
+
+		var page []Message
+		q := s.db.Query(messageQuery.ToCql()).BindMap(messageArgs)
+		if err := q.SelectRelease(&page); err != nil {
+			return nil, "", err
+		}
+
+		messages = append(messages, page...)
+		if len(messages) > limit {
+			break
+		}
+	}
+
+	nextCursor := ""
+	if len(messages) > limit {
+		messages = messages[:limit]
+		last := messages[limit-1]
+		nextCursor = EncodeCursor(last.Bucket, last.Id)
+	}
+
+	if err := attachReactions(s.db, conversationId, messages); err != nil {
+		return nil, "", err
+	}
+
+	return messages, nextCursor, nil
+}
+
+// MessageTime returns the creation time encoded in a message timeuuid.
+func MessageTime(id string) time.Time {
+	messageId, err := gocql.ParseUUID(id)
+	if err != nil {
+		return time.Time{}
+	}
+	return messageId.Time()
+}
+
+// EncodeCursor builds the opaque cursor pointing at the message id in the
+// given bucket.
+func EncodeCursor(bucket int, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(bucket) + ":" + id))
+}
+
+func DecodeCursor(cursor string) (int, string, error) {
+	if cursor == "" {
+		return 0, "", nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, "", ErrInvalidCursor
+	}
+
+	bucketStr, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return 0, "", ErrInvalidCursor
+	}
+
+	bucket, err := strconv.Atoi(bucketStr)
+	if err != nil {
+		return 0, "", ErrInvalidCursor
+	}
+
+	if _, err := gocql.ParseUUID(id); err != nil {
+		return 0, "", ErrInvalidCursor
+	}
+
+	return bucket, id, nil
+}
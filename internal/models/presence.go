@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/scylladb/gocqlx/table"
+)
+
+var userLastSeenMetaData = table.Metadata{
+	Name:    "user_last_seen",
+	Columns: []string{"user_id", "last_seen_at"},
+	PartKey: []string{"user_id"},
+}
+
+var userLastSeenTable = table.New(userLastSeenMetaData)
+
+type UserLastSeen struct {
+	UserId     string
+	LastSeenAt time.Time
+}
+
+func (s *ScyllaStore) UpdateLastSeen(userId string, lastSeenAt time.Time) error {
+	q := s.db.Query(userLastSeenTable.Insert()).BindStruct(UserLastSeen{UserId: userId, LastSeenAt: lastSeenAt})
+	return q.ExecRelease()
+}
+
+// GetLastSeen returns the zero time when the user was never seen.
+func (s *ScyllaStore) GetLastSeen(userId string) (time.Time, error) {
+	var lastSeen []UserLastSeen
+	q := s.db.Query(userLastSeenTable.Get()).BindStruct(UserLastSeen{UserId: userId})
+	if err := q.SelectRelease(&lastSeen); err != nil {
+		return time.Time{}, err
+	}
+	if len(lastSeen) == 0 {
+		return time.Time{}, nil
+	}
+	return lastSeen[0].LastSeenAt, nil
+}
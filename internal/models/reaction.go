@@ -0,0 +1,129 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/scylladb/gocqlx/qb"
+	"github.com/scylladb/gocqlx/table"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+var messageReactionMetaData = table.Metadata{
+	Name:    "message_reactions",
+	Columns: []string{"conversation_id", "bucket", "message_id", "emoji", "user_id", "created_at"},
+	PartKey: []string{"conversation_id", "bucket"},
+	SortKey: []string{"message_id", "emoji", "user_id"},
+}
+
+var messageReactionTable = table.New(messageReactionMetaData)
+
+type MessageReaction struct {
+	ConversationId string
+	Bucket         int
+	MessageId      string
+	Emoji          string
+	UserId         string
+	CreatedAt      time.Time
+}
+
+// ReactionCount is the number of users who reacted to a message with an emoji.
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+func (s *ScyllaStore) AddReaction(message *Message, emoji string, userId string) error {
+	reaction := MessageReaction{
+		ConversationId: message.ConversationId,
+		Bucket:         message.Bucket,
+		MessageId:      message.Id,
+		Emoji:          emoji,
+		UserId:         userId,
+		CreatedAt:      time.Now(),
+	}
+	q := s.db.Query(messageReactionTable.Insert()).BindStruct(reaction)
+	return q.ExecRelease()
+}
+
+func (s *ScyllaStore) RemoveReaction(message *Message, emoji string, userId string) error {
+	reaction := MessageReaction{
+		ConversationId: message.ConversationId,
+		Bucket:         message.Bucket,
+		MessageId:      message.Id,
+		Emoji:          emoji,
+		UserId:         userId,
+	}
+	q := s.db.Query(messageReactionTable.Delete()).BindStruct(reaction)
+	return q.ExecRelease()
+}
+
+func (s *ScyllaStore) CountReaction(message *Message, emoji string) (int, error) {
+	stmt, names := messageReactionTable.SelectBuilder().CountAll().Where(qb.Eq("message_id"), qb.Eq("emoji")).ToCql()
+
+	var count int
+	q := s.db.Query(stmt, names).BindMap(qb.M{
+		"conversation_id": message.ConversationId,
+		"bucket":          message.Bucket,
+		"message_id":      message.Id,
+		"emoji":           emoji,
+	})
+	if err := q.GetRelease(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// attachReactions loads the reactions of a page of messages with one query
+// per bucket the page spans.
+func attachReactions(db gocqlx.Session, conversationId string, messages []Message) error {
+	byBucket := make(map[int][]int)
+	for i := range messages {
+		byBucket[messages[i].Bucket] = append(byBucket[messages[i].Bucket], i)
+	}
+
+	for bucket, indexes := range byBucket {
+		oldest, newest := messages[indexes[0]].Id, messages[indexes[0]].Id
+		for _, i := range indexes {
+			if MessageTime(messages[i].Id).Before(MessageTime(oldest)) {
+				oldest = messages[i].Id
+			}
+			if MessageTime(messages[i].Id).After(MessageTime(newest)) {
+				newest = messages[i].Id
+			}
+		}
+
+		stmt, names := messageReactionTable.SelectBuilder("message_id", "emoji").
+			Where(qb.GtOrEqNamed("message_id", "oldest"), qb.LtOrEqNamed("message_id", "newest")).
+			ToCql()
+
+		var reactions []MessageReaction
+		q := db.Query(stmt, names).BindMap(qb.M{
+			"conversation_id": conversationId,
+			"bucket":          bucket,
+			"oldest":          oldest,
+			"newest":          newest,
+		})
+		if err := q.SelectRelease(&reactions); err != nil {
+			return err
+		}
+
+		counts := make(map[string]map[string]int)
+		for _, reaction := range reactions {
+			if counts[reaction.MessageId] == nil {
+				counts[reaction.MessageId] = make(map[string]int)
+			}
+			counts[reaction.MessageId][reaction.Emoji]++
+		}
+
+		for _, i := range indexes {
+			for emoji, count := range counts[messages[i].Id] {
+				messages[i].Reactions = append(messages[i].Reactions, ReactionCount{Emoji: emoji, Count: count})
+			}
+			sort.Slice(messages[i].Reactions, func(a, b int) bool {
+				return messages[i].Reactions[a].Emoji < messages[i].Reactions[b].Emoji
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/scylladb/gocqlx/v2"
+)
+
+// MessageStore persists messages along with their edits, threads and
+// reactions.
+type MessageStore interface {
+	InsertMessage(message *Message) error
+	GetMessage(id string) (*Message, error)
+	EditMessage(message *Message, body string) error
+	DeleteMessage(message *Message) error
+	ListMessageEdits(messageId string) ([]MessageEdit, error)
+	ListConversationMessages(conversationId string, cursor string, limit int) ([]Message, string, error)
+	UpdateThreadSummary(root *Message, reply *Message) error
+	ListThreadMessages(root *Message, cursor string, limit int) ([]Message, string, error)
+
+	AddReaction(message *Message, emoji string, userId string) error
+	RemoveReaction(message *Message, emoji string, userId string) error
+	CountReaction(message *Message, emoji string) (int, error)
+}
+
+// ChannelStore looks up channel members. Membership itself is managed outside
+// of the chat server.
+type ChannelStore interface {
+	GetChannelUserIds(channelId string) ([]string, error)
+	IsChannelUser(channelId string, userId string) (bool, error)
+}
+
+// MembershipStore tracks the conversations of each user and how far they
+// have read them.
+type MembershipStore interface {
+	RecordConversationMessage(message *Message, participantIds []string) error
+	MarkConversationRead(userId string, conversationId string, messageId string) (bool, error)
+	GetUserConversation(userId string, conversationId string) (*UserConversation, error)
+	ListUserConversations(userId string) ([]UserConversation, error)
+}
+
+type PresenceStore interface {
+	UpdateLastSeen(userId string, lastSeenAt time.Time) error
+	GetLastSeen(userId string) (time.Time, error)
+}
+
+// Store groups every store the server needs. ScyllaStore is the production
+// implementation, memory_storage provides an in-process one. Tests can embed
+// a Store and override single stores.
+type Store interface {
+	MessageStore
+	ChannelStore
+	MembershipStore
+	PresenceStore
+}
+
+type ScyllaStore struct {
+	db gocqlx.Session
+}
+
+func NewScyllaStore(db gocqlx.Session) *ScyllaStore {
+	return &ScyllaStore{db: db}
+}
@@ -0,0 +1,175 @@
+package observability
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ConnectedClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gosocket_connected_clients",
+		Help: "Client connections held by this node, on every transport.",
+	})
+
+	EventsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosocket_events_handled_total",
+		Help: "Events received from clients, by type.",
+	}, []string{"type"})
+
+	EventErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosocket_event_errors_total",
+		Help: "Events received from clients whose handler failed, by type.",
+	}, []string{"type"})
+
+	SubscribeEventsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosocket_subscribe_events_handled_total",
+		Help: "Events received from other nodes through the bus, by type.",
+	}, []string{"type"})
+
+	SubscribeEventErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosocket_subscribe_event_errors_total",
+		Help: "Events received through the bus whose handler failed, by type.",
+	}, []string{"type"})
+
+	EgressQueueDepth = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gosocket_egress_queue_depth",
+		Help:    "Depth of the egress queue of a connection when an event is queued.",
+		Buckets: []float64{0, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024},
+	})
+
+	EgressOverflows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosocket_egress_overflows_total",
+		Help: "Events that found the egress queue of a connection full, by overflow policy.",
+	}, []string{"policy"})
+
+	FrameBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosocket_websocket_frame_bytes_total",
+		Help: "Bytes of event frames written to websockets, raw before compression and as written on the wire, by whether compression was applied.",
+	}, []string{"compressed", "stage"})
+
+	UpgradeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosocket_websocket_upgrade_failures_total",
+		Help: "Websocket upgrades that failed, by stage.",
+	}, []string{"stage"})
+
+	ScyllaQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gosocket_scylla_query_duration_seconds",
+		Help:    "Latency of Scylla queries, by statement kind.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"operation", "status"})
+
+	RedisCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gosocket_redis_command_duration_seconds",
+		Help:    "Latency of Redis commands and pipelines, by command.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+	}, []string{"command", "status"})
+)
+
+func status(err error) string {
+	if err != nil && err != redis.Nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// ScyllaObserver records the latency of every query and batch sent through a
+// gocql session configured with it.
+type ScyllaObserver struct{}
+
+func (ScyllaObserver) ObserveQuery(ctx context.Context, query gocql.ObservedQuery) {
+	operation, _, _ := strings.Cut(strings.TrimSpace(query.Statement), " ")
+	ScyllaQueryDuration.WithLabelValues(strings.ToLower(operation), status(query.Err)).Observe(query.End.Sub(query.Start).Seconds())
+}
+
+func (ScyllaObserver) ObserveBatch(ctx context.Context, batch gocql.ObservedBatch) {
+	ScyllaQueryDuration.WithLabelValues("batch", status(batch.Err)).Observe(batch.End.Sub(batch.Start).Seconds())
+}
+
+// RedisHook records the latency of every command sent through a client it is
+// added to, and traces it as a child of the span of the caller.
+type RedisHook struct{}
+
+// startRedisSpan only traces commands issued within a trace, background loops
+// such as the bus readers would otherwise produce a root span per call.
+func startRedisSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := startRedisSpan(ctx, "redis "+cmd.Name())
+		defer span.End()
+
+		start := time.Now()
+		err := next(ctx, cmd)
+		RedisCommandDuration.WithLabelValues(cmd.Name(), status(err)).Observe(time.Since(start).Seconds())
+		if status(err) == "error" {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := startRedisSpan(ctx, "redis pipeline")
+		span.SetAttributes(attribute.Int("db.redis.num_cmd", len(cmds)))
+		defer span.End()
+
+		start := time.Now()
+		err := next(ctx, cmds)
+		RedisCommandDuration.WithLabelValues("pipeline", status(err)).Observe(time.Since(start).Seconds())
+		if status(err) == "error" {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+// RecordFrame records the size of an event frame before and after
+// compression.
+func RecordFrame(compressed bool, raw int, wire int) {
+	label := strconv.FormatBool(compressed)
+	FrameBytes.WithLabelValues(label, "raw").Add(float64(raw))
+	FrameBytes.WithLabelValues(label, "wire").Add(float64(wire))
+}
+
+// RegisterEgressQueues exposes the depth of the egress queues of the
+// connections, computed on scrape. A later call replaces the functions.
+func RegisterEgressQueues(total func() float64, max func() float64) {
+	collectors := []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gosocket_egress_queued_events",
+			Help: "Events waiting in the egress queues of all connections.",
+		}, total),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gosocket_egress_queue_max_depth",
+			Help: "Events waiting in the fullest egress queue.",
+		}, max),
+	}
+
+	for _, collector := range collectors {
+		prometheus.Unregister(collector)
+		prometheus.MustRegister(collector)
+	}
+}
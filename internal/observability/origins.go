@@ -0,0 +1,17 @@
+package observability
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+var RejectedOrigins = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gosocket_rejected_origins_total",
+	Help: "Requests refused because of their origin, by component.",
+}, []string{"component"})
+
+func RecordRejectedOrigin(component string, origin string) {
+	RejectedOrigins.WithLabelValues(component).Inc()
+	logrus.WithField("component", component).Warnf("rejected origin: %q", origin)
+}
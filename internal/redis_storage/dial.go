@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/hiumesh/go-chat-server/internal/observability"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -14,6 +15,7 @@ func Dial(ctx context.Context, config *conf.REDISConfiguration) (*redis.Client,
 	}
 
 	client := redis.NewClient(opt)
+	client.AddHook(observability.RedisHook{})
 
 	_, err = client.Ping(ctx).Result()
 
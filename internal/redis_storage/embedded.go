@@ -0,0 +1,48 @@
+package redis_storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hiumesh/go-chat-server/internal/observability"
+	"github.com/redis/go-redis/v9"
+)
+
+// DialEmbedded starts an in-process redis server and returns a client for
+// it. The returned function stops the server.
+func DialEmbedded(ctx context.Context) (*redis.Client, func(), error) {
+	server, err := miniredis.Run()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// miniredis only expires keys when its clock is moved forward.
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				server.FastForward(time.Second)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	client.AddHook(observability.RedisHook{})
+	if _, err := client.Ping(ctx).Result(); err != nil {
+		close(done)
+		server.Close()
+		return nil, nil, err
+	}
+
+	return client, func() {
+		close(done)
+		server.Close()
+	}, nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gocql/gocql"
 	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/hiumesh/go-chat-server/internal/observability"
 	"github.com/scylladb/gocqlx/v2"
 	"github.com/scylladb/gocqlx/v2/migrate"
 )
@@ -35,6 +36,8 @@ func GetClusterConfig(config *conf.DBConfiguration) *gocql.ClusterConfig {
 	// cluster.Keyspace = config.Keyspace
 	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
 	cluster.Consistency = gocql.LocalQuorum
+	cluster.QueryObserver = observability.ScyllaObserver{}
+	cluster.BatchObserver = observability.ScyllaObserver{}
 	return cluster
 }
 
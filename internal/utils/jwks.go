@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// minKeySetRefresh limits how often an unknown kid forces a refresh.
+const minKeySetRefresh = time.Minute
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type signingKey struct {
+	alg string
+	key interface{}
+}
+
+// KeySet holds the verification keys of a JWKS document read from a file or
+// an http(s) URL, indexed by kid.
+type KeySet struct {
+	source      string
+	client      *http.Client
+	keys        map[string]signingKey
+	refreshedAt time.Time
+	sync.RWMutex
+}
+
+func NewKeySet(source string) *KeySet {
+	return &KeySet{
+		source: source,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]signingKey),
+	}
+}
+
+// Refresh reloads the document. The previous keys are kept when it fails.
+func (k *KeySet) Refresh(ctx context.Context) error {
+	k.Lock()
+	k.refreshedAt = time.Now()
+	k.Unlock()
+
+	data, err := k.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	var document jsonWebKeySet
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	keys := make(map[string]signingKey, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			logrus.Warnf("skipping jwk %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = signingKey{alg: jwk.Alg, key: key}
+	}
+
+	k.Lock()
+	k.keys = keys
+	k.Unlock()
+	return nil
+}
+
+func (k *KeySet) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(k.source, "http://") && !strings.HasPrefix(k.source, "https://") {
+		return os.ReadFile(strings.TrimPrefix(k.source, "file://"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching jwks: %d", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// Watch refreshes the key set every interval until the context is done.
+func (k *KeySet) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := k.Refresh(ctx); err != nil {
+				logrus.Errorf("error refreshing jwks: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Key returns the key for the kid and algorithm of a token. A token without
+// kid matches when the set holds a single key. Unknown kids trigger a refresh
+// so rotated keys are picked up before the next scheduled one.
+func (k *KeySet) Key(ctx context.Context, kid string, alg string) (interface{}, error) {
+	key, err := k.lookup(kid, alg)
+	if err != ErrKeyNotFound {
+		return key, err
+	}
+
+	k.RLock()
+	recent := time.Since(k.refreshedAt) < minKeySetRefresh
+	k.RUnlock()
+	if recent {
+		return nil, err
+	}
+
+	if err := k.Refresh(ctx); err != nil {
+		logrus.Errorf("error refreshing jwks: %v", err)
+	}
+	return k.lookup(kid, alg)
+}
+
+func (k *KeySet) lookup(kid string, alg string) (interface{}, error) {
+	k.RLock()
+	defer k.RUnlock()
+
+	key, ok := k.keys[kid]
+	if !ok && kid == "" && len(k.keys) == 1 {
+		for _, only := range k.keys {
+			key, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	if key.alg != "" && key.alg != alg {
+		return nil, fmt.Errorf("signing key %q does not allow %s", kid, alg)
+	}
+	return key.key, nil
+}
+
+func (jwk *jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(jwk.K)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func encodeBigInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func rsaJWK(kid string, alg string, key *rsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Alg: alg,
+		N:   encodeBigInt(key.N),
+		E:   encodeBigInt(big.NewInt(int64(key.E))),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   encodeBigInt(key.X),
+		Y:   encodeBigInt(key.Y),
+	}
+}
+
+// jwksServer serves the keys it holds, which tests replace to rotate them.
+type jwksServer struct {
+	*httptest.Server
+	keys    []jsonWebKey
+	fetches atomic.Int32
+	sync.Mutex
+}
+
+func newJWKSServer(t *testing.T, keys ...jsonWebKey) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.Lock()
+		defer s.Unlock()
+		json.NewEncoder(w).Encode(jsonWebKeySet{Keys: s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) rotate(keys ...jsonWebKey) {
+	s.Lock()
+	defer s.Unlock()
+	s.keys = keys
+}
+
+func newTestKeySet(t *testing.T, source string) *KeySet {
+	t.Helper()
+
+	keys := NewKeySet(source)
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, &AccessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestTokenParserSelectsKeyByKid(t *testing.T) {
+	rsaKey := generateRSAKey(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newJWKSServer(t, rsaJWK("rsa", "RS256", rsaKey), ecJWK("ec", ecKey))
+	parser := NewTokenParser("", newTestKeySet(t, server.URL), nil, "")
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"rsa", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey), true},
+		{"ec", signToken(t, jwt.SigningMethodES256, "ec", ecKey), true},
+		{"kid of another key", signToken(t, jwt.SigningMethodRS256, "ec", rsaKey), false},
+		{"algorithm not allowed by the key", signToken(t, jwt.SigningMethodPS256, "rsa", rsaKey), false},
+		{"unknown kid", signToken(t, jwt.SigningMethodRS256, "other", rsaKey), false},
+		{"no kid with several keys", signToken(t, jwt.SigningMethodRS256, "", rsaKey), false},
+		{"another rsa key", signToken(t, jwt.SigningMethodRS256, "rsa", generateRSAKey(t)), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parser.Parse(ctx, test.token)
+			if test.valid && err != nil {
+				t.Errorf("rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("accepted")
+			}
+		})
+	}
+}
+
+func TestTokenParserAcceptsSingleKeyWithoutKid(t *testing.T) {
+	rsaKey := generateRSAKey(t)
+	server := newJWKSServer(t, rsaJWK("rsa", "", rsaKey))
+	parser := NewTokenParser("", newTestKeySet(t, server.URL), nil, "")
+
+	if _, err := parser.Parse(context.Background(), signToken(t, jwt.SigningMethodRS256, "", rsaKey)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokenParserKeepsSharedSecret(t *testing.T) {
+	server := newJWKSServer(t, rsaJWK("rsa", "RS256", generateRSAKey(t)))
+	parser := NewTokenParser("secret", newTestKeySet(t, server.URL), nil, "")
+
+	if _, err := parser.Parse(context.Background(), signToken(t, jwt.SigningMethodHS256, "", []byte("secret"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeySetRefreshesOnUnknownKid(t *testing.T) {
+	oldKey, newKey := generateRSAKey(t), generateRSAKey(t)
+	server := newJWKSServer(t, rsaJWK("old", "RS256", oldKey))
+	keys := newTestKeySet(t, server.URL)
+	parser := NewTokenParser("", keys, nil, "")
+	ctx := context.Background()
+
+	server.rotate(rsaJWK("new", "RS256", newKey))
+	token := signToken(t, jwt.SigningMethodRS256, "new", newKey)
+
+	// Refreshes are rate limited, the set was just loaded.
+	if _, err := parser.Parse(ctx, token); err == nil {
+		t.Fatal("rotated key accepted before the refresh")
+	}
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Fatalf("got %d fetches, want 1", fetches)
+	}
+
+	keys.Lock()
+	keys.refreshedAt = time.Now().Add(-minKeySetRefresh)
+	keys.Unlock()
+
+	if _, err := parser.Parse(ctx, token); err != nil {
+		t.Fatalf("rotated key rejected: %v", err)
+	}
+	if _, err := parser.Parse(ctx, signToken(t, jwt.SigningMethodRS256, "old", oldKey)); err == nil {
+		t.Error("retired key accepted")
+	}
+}
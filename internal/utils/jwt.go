@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var asymmetricMethods = []string{
+	jwt.SigningMethodRS256.Name, jwt.SigningMethodRS384.Name, jwt.SigningMethodRS512.Name,
+	jwt.SigningMethodPS256.Name, jwt.SigningMethodPS384.Name, jwt.SigningMethodPS512.Name,
+	jwt.SigningMethodES256.Name, jwt.SigningMethodES384.Name, jwt.SigningMethodES512.Name,
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+var hmacMethods = []string{jwt.SigningMethodHS256.Name, jwt.SigningMethodHS384.Name, jwt.SigningMethodHS512.Name}
+
+// TokenParser verifies access tokens signed either with the shared secret
+// (HS256) or with a key of the JWKS selected by kid.
+type TokenParser struct {
+	secret   string
+	keys     *KeySet
+	audience []string
+	issuer   string
+	parser   jwt.Parser
+}
+
+func NewTokenParser(secret string, keys *KeySet, audience []string, issuer string) *TokenParser {
+	var methods []string
+	if secret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Name)
+	}
+	if keys != nil {
+		methods = append(methods, asymmetricMethods...)
+		if secret == "" {
+			methods = append(methods, hmacMethods...)
+		}
+	}
+
+	return &TokenParser{
+		secret:   secret,
+		keys:     keys,
+		audience: audience,
+		issuer:   issuer,
+		parser:   jwt.Parser{ValidMethods: methods},
+	}
+}
+
+func (p *TokenParser) Parse(ctx context.Context, bearer string) (*jwt.Token, error) {
+	token, err := p.parser.ParseWithClaims(bearer, &AccessTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && p.secret != "" && (kid == "" || p.keys == nil) {
+			return []byte(p.secret), nil
+		}
+		if p.keys == nil {
+			return nil, ErrKeyNotFound
+		}
+		return p.keys.Key(ctx, kid, token.Method.Alg())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(*AccessTokenClaims)
+	if p.issuer != "" && !claims.VerifyIssuer(p.issuer, true) {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if len(p.audience) > 0 && !p.verifyAudience(claims) {
+		return nil, errors.New("token is not intended for this audience")
+	}
+	return token, nil
+}
+
+func (p *TokenParser) verifyAudience(claims *AccessTokenClaims) bool {
+	for _, audience := range p.audience {
+		if claims.VerifyAudience(audience, true) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventBatch wraps events coalesced into a single frame for clients that
+// connected with batching enabled. Its payload is the array of events.
+const EventBatch = "batch"
+
+// drainEgress appends the events already waiting in the egress queue, waiting
+// up to the configured flush latency for more, until the batch is full.
+func (c *Client) drainEgress(events []Event) []Event {
+	config := c.manager.config.SERVER
+
+	var flush <-chan time.Time
+	if config.BatchFlushLatency > 0 {
+		timer := time.NewTimer(config.BatchFlushLatency)
+		defer timer.Stop()
+		flush = timer.C
+	}
+
+	for len(events) < config.BatchMaxSize {
+		if flush == nil {
+			select {
+			case event, ok := <-c.egress:
+				if !ok {
+					return events
+				}
+				events = append(events, event)
+			default:
+				return events
+			}
+			continue
+		}
+
+		select {
+		case event, ok := <-c.egress:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		case <-flush:
+			return events
+		}
+	}
+	return events
+}
+
+// encodeEvents encodes a single event as is and several as a batch event.
+func encodeEvents(codec Codec, events []Event) ([]byte, error) {
+	if len(events) == 1 {
+		return codec.EncodeEvent(events[0])
+	}
+
+	payload, err := json.Marshal(events)
+	if err != nil {
+		return nil, err
+	}
+	return codec.EncodeEvent(Event{Type: EventBatch, Payload: payload})
+}
@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDrainEgressStopsAtBatchMaxSize(t *testing.T) {
+	m := newTestManager(t)
+	m.config.SERVER.BatchMaxSize = 3
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+
+	fillEgress(c, 4)
+
+	events := c.drainEgress([]Event{<-c.egress})
+	if len(events) != 3 || events[0].Seq != 1 || events[2].Seq != 3 {
+		t.Fatalf("got %+v, want the first 3 events", events)
+	}
+	if events := c.drainEgress(nil); len(events) != 1 || events[0].Seq != 4 {
+		t.Errorf("got %+v, want the last event", events)
+	}
+}
+
+func TestDrainEgressWaitsForFlushLatency(t *testing.T) {
+	m := newTestManager(t)
+	m.config.SERVER.BatchFlushLatency = 200 * time.Millisecond
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		c.enqueue(Event{Type: EventNewMessage, Seq: 2})
+	}()
+
+	events := c.drainEgress([]Event{{Type: EventNewMessage, Seq: 1}})
+	if len(events) != 2 || events[1].Seq != 2 {
+		t.Errorf("got %+v, want the event queued within the flush latency", events)
+	}
+}
+
+func TestEncodeEventsWrapsSeveralInABatch(t *testing.T) {
+	events := []Event{
+		{Type: EventNewMessage, Payload: json.RawMessage(`{"body":"a"}`), Seq: 1},
+		{Type: EventNewMessage, Payload: json.RawMessage(`{"body":"b"}`), Seq: 2},
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := encodeEvents(codec, events[:1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			single, err := codec.DecodeEvent(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if single.Type != EventNewMessage || single.Seq != 1 {
+				t.Errorf("got %+v, want the event unwrapped", single)
+			}
+
+			data, err = encodeEvents(codec, events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch, err := codec.DecodeEvent(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var batched []Event
+			if err := json.Unmarshal(batch.Payload, &batched); err != nil {
+				t.Fatal(err)
+			}
+			if batch.Type != EventBatch || len(batched) != 2 || batched[1].Seq != 2 {
+				t.Errorf("got %s with %+v, want a batch of both events", batch.Type, batched)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/hiumesh/go-chat-server/internal/observability"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	BusDriverStreams = "streams"
+	BusDriverPubSub  = "pubsub"
+	BusDriverMemory  = "memory"
+)
+
+// Bus carries subscribe events to the server owning the target connections.
+type Bus interface {
+	// Publish hands the event to the server identified by serverId.
+	Publish(ctx context.Context, serverId string, event SubscribeEvent) error
+	// Subscribe calls handler for every event published to serverId and
+	// blocks until the context is done or the bus fails.
+	Subscribe(ctx context.Context, serverId string, handler func(SubscribeEvent) error) error
+	Close() error
+}
+
+// publish hands the event to the bus inside a producer span whose context
+// travels with the event.
+func publish(ctx context.Context, bus Bus, serverId string, event SubscribeEvent) error {
+	ctx, span := observability.Tracer.Start(ctx, "bus.publish "+event.Type,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("gosocket.server_id", serverId)))
+	defer span.End()
+
+	event.TraceContext = make(map[string]string)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(event.TraceContext))
+
+	if err := bus.Publish(ctx, serverId, event); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+const maxBusBackoff = 30 * time.Second
+
+// sleepBackoff waits before the next attempt at a failing bus operation,
+// doubling from 100ms up to maxBusBackoff. It reports false when the context
+// is done first.
+func sleepBackoff(ctx context.Context, attempt int) bool {
+	wait := maxBusBackoff
+	if attempt < 9 {
+		wait = min(100*time.Millisecond<<attempt, maxBusBackoff)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func NewBus(config *conf.BusConfiguration, rdb *redis.Client) (Bus, error) {
+	codec, err := NewCodec(config.Codec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.Driver {
+	case BusDriverStreams:
+		return NewRedisStreamBus(rdb, config, codec), nil
+	case BusDriverPubSub:
+		return NewRedisPubSubBus(rdb, codec), nil
+	case BusDriverMemory:
+		return NewMemoryBus(), nil
+	default:
+		return nil, fmt.Errorf("unsupported bus driver: %q", config.Driver)
+	}
+}
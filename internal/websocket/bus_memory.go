@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const memoryBusBuffer = 1024
+
+var ErrBusClosed = errors.New("bus is closed")
+
+// MemoryBus delivers events between managers of the same process. It is
+// meant for single-node deployments and tests. Queues are never closed, done
+// ends pending publishes and subscriptions instead.
+type MemoryBus struct {
+	queues    map[string]chan SubscribeEvent
+	done      chan struct{}
+	closeOnce sync.Once
+	sync.Mutex
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{queues: make(map[string]chan SubscribeEvent), done: make(chan struct{})}
+}
+
+func (b *MemoryBus) queue(serverId string) (chan SubscribeEvent, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	select {
+	case <-b.done:
+		return nil, ErrBusClosed
+	default:
+	}
+
+	queue, ok := b.queues[serverId]
+	if !ok {
+		queue = make(chan SubscribeEvent, memoryBusBuffer)
+		b.queues[serverId] = queue
+	}
+	return queue, nil
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, serverId string, event SubscribeEvent) error {
+	queue, err := b.queue(serverId)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case queue <- event:
+		return nil
+	case <-b.done:
+		return ErrBusClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *MemoryBus) Subscribe(ctx context.Context, serverId string, handler func(SubscribeEvent) error) error {
+	queue, err := b.queue(serverId)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case event := <-queue:
+			if err := handler(event); err != nil {
+				logrus.Errorf("error handeling subscribe event: %v", err)
+			}
+		case <-b.done:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (b *MemoryBus) Close() error {
+	b.closeOnce.Do(func() { close(b.done) })
+	return nil
+}
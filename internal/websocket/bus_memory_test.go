@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryBusDeliversToSubscriber(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan SubscribeEvent, 1)
+	go bus.Subscribe(ctx, "server", func(event SubscribeEvent) error {
+		received <- event
+		return nil
+	})
+
+	if err := bus.Publish(ctx, "server", SubscribeEvent{Type: SubscribeEventDeliver}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-received:
+		if event.Type != SubscribeEventDeliver {
+			t.Errorf("got %q, want %q", event.Type, SubscribeEventDeliver)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+}
+
+func TestMemoryBusCloseEndsBlockedPublish(t *testing.T) {
+	bus := NewMemoryBus()
+	ctx := context.Background()
+
+	// Nobody subscribes, the queue fills up and the next publish blocks.
+	for i := 0; i < memoryBusBuffer; i++ {
+		if err := bus.Publish(ctx, "server", SubscribeEvent{Type: SubscribeEventDeliver}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	published := make(chan error, 1)
+	go func() {
+		published <- bus.Publish(ctx, "server", SubscribeEvent{Type: SubscribeEventDeliver})
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		bus.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close blocked by a pending publish")
+	}
+	select {
+	case err := <-published:
+		if !errors.Is(err, ErrBusClosed) {
+			t.Errorf("got %v, want %v", err, ErrBusClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("publish not ended by close")
+	}
+
+	if err := bus.Subscribe(ctx, "server", func(SubscribeEvent) error { return nil }); !errors.Is(err, ErrBusClosed) {
+		t.Errorf("got %v subscribing to a closed bus, want %v", err, ErrBusClosed)
+	}
+}
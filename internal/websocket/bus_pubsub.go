@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// RedisPubSubBus publishes on a Redis channel named after the server id.
+// Events published while the subscriber is reconnecting are lost.
+type RedisPubSubBus struct {
+	rdb   *redis.Client
+	codec Codec
+}
+
+func NewRedisPubSubBus(rdb *redis.Client, codec Codec) *RedisPubSubBus {
+	return &RedisPubSubBus{rdb: rdb, codec: codec}
+}
+
+func (b *RedisPubSubBus) Publish(ctx context.Context, serverId string, event SubscribeEvent) error {
+	payload, err := b.codec.EncodeSubscribeEvent(event)
+	if err != nil {
+		return err
+	}
+	return b.rdb.Publish(ctx, serverId, payload).Err()
+}
+
+func (b *RedisPubSubBus) Subscribe(ctx context.Context, serverId string, handler func(SubscribeEvent) error) error {
+	sub := b.rdb.Subscribe(ctx, serverId)
+	defer sub.Close()
+
+	for {
+		msg, err := sub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		event, err := b.codec.DecodeSubscribeEvent([]byte(msg.Payload))
+		if err != nil {
+			logrus.Errorf("error unmarshalling subscribe event: %v", err)
+			continue
+		}
+
+		if err := handler(event); err != nil {
+			logrus.Errorf("error handeling subscribe event: %v", err)
+		}
+	}
+}
+
+func (b *RedisPubSubBus) Close() error {
+	return nil
+}
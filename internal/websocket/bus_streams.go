@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const streamBusGroup = "gosocket"
+const streamBusPayloadField = "payload"
+
+var streamBusBlock = 5 * time.Second
+
+// RedisStreamBus appends events to a Redis stream per server and consumes it
+// through a consumer group, so events published while the subscriber is
+// reconnecting are read once it is back and only removed from the pending
+// list after they were handled.
+type RedisStreamBus struct {
+	rdb       *redis.Client
+	maxLen    int64
+	retention time.Duration
+	codec     Codec
+}
+
+func NewRedisStreamBus(rdb *redis.Client, config *conf.BusConfiguration, codec Codec) *RedisStreamBus {
+	return &RedisStreamBus{rdb: rdb, maxLen: config.StreamMaxLen, retention: config.StreamTTL, codec: codec}
+}
+
+func streamKey(serverId string) string {
+	return "bus:" + serverId
+}
+
+func (b *RedisStreamBus) Publish(ctx context.Context, serverId string, event SubscribeEvent) error {
+	payload, err := b.codec.EncodeSubscribeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	key := streamKey(serverId)
+	_, err = b.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: key,
+			MaxLen: b.maxLen,
+			Approx: true,
+			Values: map[string]interface{}{streamBusPayloadField: payload},
+		})
+		// the key must not expire, it holds the consumer group, old events
+		// are trimmed instead
+		minId := strconv.FormatInt(time.Now().Add(-b.retention).UnixMilli(), 10)
+		pipe.XTrimMinIDApprox(ctx, key, minId, 0)
+		return nil
+	})
+	return err
+}
+
+func (b *RedisStreamBus) Subscribe(ctx context.Context, serverId string, handler func(SubscribeEvent) error) error {
+	key := streamKey(serverId)
+
+	if err := b.createGroup(ctx, key, "$"); err != nil {
+		return err
+	}
+
+	// "0" first drains the events read but not acknowledged before a
+	// reconnect, ">" then reads new ones
+	lastId := "0"
+	for attempt := 0; ; {
+		streams, err := b.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    streamBusGroup,
+			Consumer: serverId,
+			Streams:  []string{key, lastId},
+			Count:    100,
+			Block:    streamBusBlock,
+		}).Result()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			logrus.Errorf("error reading subscribe events: %v", err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				// the stream was removed, whatever it holds now is unread
+				err = b.createGroup(ctx, key, "0")
+				lastId = "0"
+			}
+			if err != nil {
+				logrus.Errorf("error creating the consumer group: %v", err)
+			}
+			if !sleepBackoff(ctx, attempt) {
+				return nil
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		for _, stream := range streams {
+			if lastId != ">" && len(stream.Messages) == 0 {
+				lastId = ">"
+			}
+
+			for _, message := range stream.Messages {
+				b.handle(ctx, key, message, handler)
+			}
+		}
+	}
+}
+
+func (b *RedisStreamBus) createGroup(ctx context.Context, key string, start string) error {
+	err := b.rdb.XGroupCreateMkStream(ctx, key, streamBusGroup, start).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (b *RedisStreamBus) handle(ctx context.Context, key string, message redis.XMessage, handler func(SubscribeEvent) error) {
+	defer func() {
+		if err := b.rdb.XAck(ctx, key, streamBusGroup, message.ID).Err(); err != nil {
+			logrus.Errorf("error acknowledging subscribe event: %v", err)
+		}
+	}()
+
+	payload, ok := message.Values[streamBusPayloadField].(string)
+	if !ok {
+		logrus.Errorf("subscribe event %s has no payload", message.ID)
+		return
+	}
+
+	event, err := b.codec.DecodeSubscribeEvent([]byte(payload))
+	if err != nil {
+		logrus.Errorf("error unmarshalling subscribe event: %v", err)
+		return
+	}
+
+	if err := handler(event); err != nil {
+		logrus.Errorf("error handeling subscribe event: %v", err)
+	}
+}
+
+func (b *RedisStreamBus) Close() error {
+	return nil
+}
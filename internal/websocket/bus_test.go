@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiumesh/go-chat-server/internal/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingBus keeps the published events instead of delivering them.
+type recordingBus struct {
+	events []SubscribeEvent
+}
+
+func (b *recordingBus) Publish(ctx context.Context, serverId string, event SubscribeEvent) error {
+	b.events = append(b.events, event)
+	return nil
+}
+
+func (b *recordingBus) Subscribe(ctx context.Context, serverId string, handler func(SubscribeEvent) error) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *recordingBus) Close() error {
+	return nil
+}
+
+// recordSpans routes the spans of the server to the returned recorder for the
+// duration of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer, propagator := observability.Tracer, otel.GetTextMapPropagator()
+	observability.Tracer = provider.Tracer("test")
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		observability.Tracer = tracer
+		otel.SetTextMapPropagator(propagator)
+		provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func TestBusEventsContinueThePublisherTrace(t *testing.T) {
+	m := newTestManager(t)
+	recorder := recordSpans(t)
+
+	ctx, parent := observability.Tracer.Start(context.Background(), "event")
+	bus := &recordingBus{}
+	if err := publish(ctx, bus, "server", SubscribeEvent{Type: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	if len(bus.events) != 1 || bus.events[0].TraceContext["traceparent"] == "" {
+		t.Fatalf("got %+v, want an event carrying the trace context", bus.events)
+	}
+	m.routeSubscribeEvent(context.Background(), bus.events[0])
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	published, received := spans["bus.publish test"], spans["bus.receive test"]
+	if published == nil || received == nil {
+		t.Fatalf("got spans %v, want the publish and receive spans", spans)
+	}
+
+	if published.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("publish span not started within the caller span")
+	}
+	if received.Parent().SpanID() != published.SpanContext().SpanID() || !received.Parent().IsRemote() {
+		t.Error("receive span does not continue the publish span")
+	}
+	if received.SpanContext().TraceID() != parent.SpanContext().TraceID() || received.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("got receive span %v in trace %v, want a consumer in trace %v", received.SpanKind(), received.SpanContext().TraceID(), parent.SpanContext().TraceID())
+	}
+}
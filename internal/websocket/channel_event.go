@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+const EventSendChannelMessage = "channel_message"
+const EventNewChannelMessage = "new_channel_message"
+
+var ErrNotChannelMember = errors.New("user is not a member of the channel")
+
+type SendChannelMessageEvent struct {
+	ChannelId    string `json:"channel_id"`
+	ThreadRootId string `json:"thread_root_id,omitempty"`
+	Body         string `json:"body"`
+}
+
+type NewChannelMessageEvent struct {
+	Id             string    `json:"id"`
+	ConversationId string    `json:"conversation_id"`
+	ChannelId      string    `json:"channel_id"`
+	From           string    `json:"from"`
+	Body           string    `json:"body"`
+	Sent           time.Time `json:"sent"`
+}
+
+func SendChannelMessageHandler(ctx context.Context, event Event, c *Client) error {
+	manager := c.manager
+	var chatevent SendChannelMessageEvent
+	if err := json.Unmarshal(event.Payload, &chatevent); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	if chatevent.ChannelId == "" {
+		return errors.New("channel id is required")
+	}
+
+	_, memberIds, err := manager.conversationParticipants(c.claims(), chatevent.ChannelId, "")
+	if err != nil {
+		return err
+	}
+
+	dbMessage := models.Message{
+		ConversationId: chatevent.ChannelId,
+		ChannelId:      chatevent.ChannelId,
+		ThreadRootId:   chatevent.ThreadRootId,
+		UserId:         c.claims().Subject,
+		Body:           chatevent.Body,
+	}
+
+	if dbMessage.ThreadRootId != "" {
+		root, err := manager.validateThreadReply(c.claims(), &dbMessage)
+		if err != nil {
+			return err
+		}
+
+		if err := manager.insertThreadReply(ctx, root, &dbMessage); err != nil {
+			return err
+		}
+
+		return manager.deliverThreadReply(ctx, root, &dbMessage, memberIds)
+	}
+
+	if err := manager.insertMessage(ctx, &dbMessage); err != nil {
+		return err
+	}
+
+	if err := manager.store.RecordConversationMessage(&dbMessage, memberIds); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(NewChannelMessageEvent{
+		Id:             dbMessage.Id,
+		ConversationId: dbMessage.ConversationId,
+		ChannelId:      dbMessage.ChannelId,
+		From:           dbMessage.UserId,
+		Body:           dbMessage.Body,
+		Sent:           dbMessage.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	outgoingEvent := Event{Type: EventNewChannelMessage, Payload: data}
+
+	return manager.deliverToUsers(ctx, memberIds, outgoingEvent)
+}
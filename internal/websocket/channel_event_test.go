@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/memory_storage"
+)
+
+func channelMessageEvent(t *testing.T, message SendChannelMessageEvent) Event {
+	t.Helper()
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Event{Type: EventSendChannelMessage, Payload: data}
+}
+
+func TestChannelMessagesReachMembersOnly(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	carol := newTestClient(t, m, testClaims("carol", time.Hour))
+	for _, c := range []*Client{alice, bob, carol} {
+		registerTestClient(t, c)
+	}
+	ctx := context.Background()
+
+	store := m.store.(*memory_storage.Store)
+	store.AddChannelUser("general", "alice")
+	store.AddChannelUser("general", "bob")
+
+	send := channelMessageEvent(t, SendChannelMessageEvent{ChannelId: "general", Body: "hello"})
+	if err := SendChannelMessageHandler(ctx, send, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []*Client{alice, bob} {
+		var message NewChannelMessageEvent
+		if err := json.Unmarshal(nextEventOfType(t, c, EventNewChannelMessage).Payload, &message); err != nil {
+			t.Fatal(err)
+		}
+		if message.ChannelId != "general" || message.From != "alice" || message.Body != "hello" {
+			t.Errorf("got %+v, want hello from alice in general", message)
+		}
+	}
+	select {
+	case event := <-carol.egress:
+		t.Errorf("got %q outside the channel", event.Type)
+	default:
+	}
+
+	err := SendChannelMessageHandler(ctx, send, carol)
+	if !errors.Is(err, ErrNotChannelMember) {
+		t.Errorf("got %v, want %v", err, ErrNotChannelMember)
+	}
+}
@@ -1,9 +1,10 @@
 package websocket
 
 import (
-	"encoding/json"
 	"errors"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,18 +18,54 @@ var pongWait = 10 * time.Second
 var pingInterval = (pongWait * 9) / 10
 var redisPingInterval = 60000000000
 
+// MaxEventSize bounds the events received from clients. It leaves room for
+// refresh_token events, which carry a whole JWT.
+const MaxEventSize = 16 << 10
+
 type ClientList map[string]*Client
 
 type Client struct {
-	claims       *utils.AccessTokenClaims
-	connectionId string
-	connection   *_websocket.Conn
-	manager      *Manager
-	egress       chan Event
-	chatroom     string
+	// accessClaims is replaced on refresh_token while other goroutines read
+	// it, go through claims.
+	accessClaims  atomic.Pointer[utils.AccessTokenClaims]
+	connectionId  string
+	connection    *_websocket.Conn
+	manager       *Manager
+	egress        chan Event
+	chatroom      string
+	typing        map[string]*typingState
+	typingLock    sync.Mutex
+	threads       map[string]bool
+	threadsLock   sync.Mutex
+	expiryWarning *time.Timer
+	expiryTimer   *time.Timer
+	expiryLock    sync.Mutex
+	closeOnce     sync.Once
+	batching      bool
+	codec         Codec
+	compression   bool
+	wire          *countingConn
+	// done is closed once the client is removed from the manager, it ends
+	// the transports without a websocket.
+	done      chan struct{}
+	closeCode int
+	closeText string
+	polling   atomic.Bool
+	polledAt  atomic.Int64
+	overflows atomic.Int64
+	// unsent holds the events of a poll response that failed to be written,
+	// only the current poll touches it.
+	unsent []Event
+	// lastSeq is the sequence number of the last event queued live, see
+	// deliverSequenced.
+	seqLock    sync.Mutex
+	lastSeq    int64
+	resuming   int
+	heldEvents map[int64]Event
+	gaps       chan struct{}
 }
 
-func NewClient(ctx *gin.Context, conn *_websocket.Conn, m *Manager) (*Client, error) {
+func NewClient(ctx *gin.Context, conn *_websocket.Conn, m *Manager, codec Codec) (*Client, error) {
 	uniqueConnectionId := utils.GetRequestID(ctx)
 	if uniqueConnectionId == "" {
 		return nil, errors.New("unique id not found")
@@ -38,47 +75,56 @@ func NewClient(ctx *gin.Context, conn *_websocket.Conn, m *Manager) (*Client, er
 		return nil, errors.New("claims not found")
 	}
 
-	key := claims.Subject
-	value := m.config.SERVER.Id + " " + uniqueConnectionId
-
-	if err := m.rdb.ZRemRangeByScore(ctx, key, "-inf", strconv.Itoa(int(time.Now().UnixMilli()-300000))).Err(); err != nil {
-		return nil, err
-	}
-
-	count, err := m.rdb.ZCount(ctx, key, "-inf", "+inf").Result()
+	mspu, err := strconv.Atoi(m.config.SERVER.MaxPerUserConnection)
 	if err != nil {
 		return nil, err
 	}
 
-	mspu, err := strconv.Atoi(m.config.SERVER.MaxPerUserConnection)
-
-	if err != nil {
+	// Events sequenced from now on reach this connection, earlier ones are
+	// left to resume.
+	lastSeq, err := m.rdb.Get(ctx, sequenceKey(claims.Subject)).Int64()
+	if err != nil && err != redis.Nil {
 		return nil, err
 	}
-	if count >= int64(mspu) {
-		return nil, errors.New("maximum connection limit reached")
-	}
-	if err := m.rdb.ZAdd(ctx, key, redis.Z{Score: float64(time.Now().UnixMilli()), Member: value}).Err(); err != nil {
+
+	value := m.config.SERVER.Id + " " + uniqueConnectionId
+	if err := m.registry.Register(ctx, claims.Subject, value, mspu, 5*time.Minute); err != nil {
 		return nil, err
 	}
 
-	return &Client{
-		claims:       claims,
+	client := &Client{
 		connectionId: uniqueConnectionId,
 		connection:   conn,
 		manager:      m,
-		egress:       make(chan Event),
-	}, nil
+		egress:       make(chan Event, m.config.SERVER.EgressQueueSize),
+		typing:       make(map[string]*typingState),
+		threads:      make(map[string]bool),
+		batching:     ctx.Query("batch") == "true",
+		codec:        codec,
+		done:         make(chan struct{}),
+		lastSeq:      lastSeq,
+		heldEvents:   make(map[int64]Event),
+		gaps:         make(chan struct{}, 1),
+	}
+	client.accessClaims.Store(claims)
+	return client, nil
+}
+
+// claims returns the claims of the token the client currently holds.
+func (c *Client) claims() *utils.AccessTokenClaims {
+	return c.accessClaims.Load()
 }
 
 func (c *Client) readMessage(ctx *gin.Context) {
 	defer func() {
+		c.stopAllTyping()
+		c.stopExpiry()
 		c.manager.removeClient(c)
 
 		logrus.Debugf("exiting reader: %v", c.connectionId)
 	}()
 
-	c.connection.SetReadLimit(512)
+	c.connection.SetReadLimit(MaxEventSize)
 	if err := c.connection.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
 		logrus.Errorf("error configuring the connection: %v", err)
 		return
@@ -95,8 +141,8 @@ func (c *Client) readMessage(ctx *gin.Context) {
 			return
 		}
 
-		var request Event
-		if err := json.Unmarshal(payload, &request); err != nil {
+		request, err := c.codec.DecodeEvent(payload)
+		if err != nil {
 			logrus.Errorf("error marshalling message: %v", err)
 			continue
 		}
@@ -108,15 +154,18 @@ func (c *Client) readMessage(ctx *gin.Context) {
 }
 
 func (c *Client) writeMessages(ctx *gin.Context) {
-	claims := utils.GetClaims(ctx)
-	value := c.manager.config.SERVER.Id + " " + c.connectionId
+	claims := c.claims()
+	value := c.registryEntry()
 	ticker := time.NewTicker(pingInterval)
 	redisPingTicker := time.NewTicker(time.Duration(redisPingInterval))
 	defer func() {
 		ticker.Stop()
 		redisPingTicker.Stop()
-		c.manager.rdb.SRem(ctx, claims.Subject, value).Err()
+		if err := c.manager.registry.Unregister(ctx, claims.Subject, value); err != nil {
+			logrus.Errorf("error removing connection from registry: %v", err)
+		}
 		c.manager.removeClient(c)
+		c.manager.userDisconnected(ctx, claims.Subject)
 
 		logrus.Debugf("exiting writer: %v", c.connectionId)
 	}()
@@ -131,13 +180,18 @@ func (c *Client) writeMessages(ctx *gin.Context) {
 				return
 			}
 
-			data, err := json.Marshal(message)
+			events := []Event{message}
+			if c.batching {
+				events = c.drainEgress(events)
+			}
+
+			data, err := encodeEvents(c.codec, events)
 			if err != nil {
 				logrus.Errorf("error marshaling the socket message: %v", err)
 				continue
 			}
 
-			if err := c.connection.WriteMessage(_websocket.TextMessage, data); err != nil {
+			if err := c.writeFrame(data); err != nil {
 				logrus.Errorf("error writing the socket message: %v", err)
 			}
 			logrus.Debugf("message sent")
@@ -148,7 +202,7 @@ func (c *Client) writeMessages(ctx *gin.Context) {
 			}
 
 		case <-redisPingTicker.C:
-			if err := c.manager.rdb.ZIncrBy(ctx, claims.Subject, 60000, value).Err(); err != nil {
+			if err := c.manager.registry.Heartbeat(ctx, claims.Subject, value); err != nil {
 				logrus.Errorf("redis ping fail: %v", err)
 			}
 		}
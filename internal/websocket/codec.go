@@ -0,0 +1,99 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	_websocket "github.com/gorilla/websocket"
+)
+
+const (
+	CodecJSON    = "json"
+	CodecMsgpack = "msgpack"
+	CodecProto   = "proto"
+)
+
+// CodecSubprotocolPrefix is prepended to the codec name to form the
+// subprotocol a client offers to select it, e.g. chat.msgpack.
+const CodecSubprotocolPrefix = "chat."
+
+// Codec encodes events on the socket and subscribe events on the bus.
+// Payloads stay json.RawMessage inside the server, codecs convert them at the
+// edges.
+type Codec interface {
+	Name() string
+	// MessageType is the websocket frame type carrying the encoded events.
+	MessageType() int
+	EncodeEvent(event Event) ([]byte, error)
+	DecodeEvent(data []byte) (Event, error)
+	EncodeSubscribeEvent(event SubscribeEvent) ([]byte, error)
+	DecodeSubscribeEvent(data []byte) (SubscribeEvent, error)
+}
+
+var codecs = map[string]Codec{
+	CodecJSON:    jsonCodec{},
+	CodecMsgpack: msgpackCodec{},
+	CodecProto:   protoCodec{},
+}
+
+func NewCodec(name string) (Codec, error) {
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported codec: %q", name)
+	}
+	return codec, nil
+}
+
+// negotiateCodec picks the first codec subprotocol offered by the client and
+// returns it along with the subprotocol to accept. Clients offering none get
+// JSON, the bearer subprotocol is still accepted for them.
+func negotiateCodec(r *http.Request) (Codec, string) {
+	bearer := false
+	for _, protocol := range _websocket.Subprotocols(r) {
+		if name, ok := strings.CutPrefix(protocol, CodecSubprotocolPrefix); ok {
+			if codec, ok := codecs[name]; ok {
+				return codec, protocol
+			}
+		}
+		if protocol == BearerSubprotocol {
+			bearer = true
+		}
+	}
+
+	if bearer {
+		return jsonCodec{}, BearerSubprotocol
+	}
+	return jsonCodec{}, ""
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return CodecJSON
+}
+
+func (jsonCodec) MessageType() int {
+	return _websocket.TextMessage
+}
+
+func (jsonCodec) EncodeEvent(event Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+func (jsonCodec) DecodeEvent(data []byte) (Event, error) {
+	var event Event
+	err := json.Unmarshal(data, &event)
+	return event, err
+}
+
+func (jsonCodec) EncodeSubscribeEvent(event SubscribeEvent) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+func (jsonCodec) DecodeSubscribeEvent(data []byte) (SubscribeEvent, error) {
+	var event SubscribeEvent
+	err := json.Unmarshal(data, &event)
+	return event, err
+}
@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+
+	_websocket "github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// msgpackCodec encodes payloads as native MessagePack values rather than
+// embedded JSON so clients decode a frame in a single pass.
+type msgpackCodec struct{}
+
+type msgpackEvent struct {
+	Type    string      `msgpack:"type"`
+	Payload interface{} `msgpack:"payload"`
+	Seq     int64       `msgpack:"seq,omitempty"`
+}
+
+type msgpackSubscribeEvent struct {
+	Type         string            `msgpack:"type"`
+	Payload      interface{}       `msgpack:"payload"`
+	TraceContext map[string]string `msgpack:"trace_context,omitempty"`
+}
+
+func (msgpackCodec) Name() string {
+	return CodecMsgpack
+}
+
+func (msgpackCodec) MessageType() int {
+	return _websocket.BinaryMessage
+}
+
+func (msgpackCodec) EncodeEvent(event Event) ([]byte, error) {
+	payload, err := nativePayload(event.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(msgpackEvent{Type: event.Type, Payload: payload, Seq: event.Seq})
+}
+
+func (msgpackCodec) DecodeEvent(data []byte) (Event, error) {
+	var decoded msgpackEvent
+	if err := msgpack.Unmarshal(data, &decoded); err != nil {
+		return Event{}, err
+	}
+
+	payload, err := jsonPayload(decoded.Payload)
+	return Event{Type: decoded.Type, Payload: payload, Seq: decoded.Seq}, err
+}
+
+func (msgpackCodec) EncodeSubscribeEvent(event SubscribeEvent) ([]byte, error) {
+	payload, err := nativePayload(event.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(msgpackSubscribeEvent{Type: event.Type, Payload: payload, TraceContext: event.TraceContext})
+}
+
+func (msgpackCodec) DecodeSubscribeEvent(data []byte) (SubscribeEvent, error) {
+	var decoded msgpackSubscribeEvent
+	if err := msgpack.Unmarshal(data, &decoded); err != nil {
+		return SubscribeEvent{}, err
+	}
+
+	payload, err := jsonPayload(decoded.Payload)
+	return SubscribeEvent{Type: decoded.Type, Payload: payload, TraceContext: decoded.TraceContext}, err
+}
+
+// nativePayload decodes a JSON payload into maps, slices and scalars, keeping
+// integers as int64 so they are not widened to floats on the wire.
+func nativePayload(payload json.RawMessage) (interface{}, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return nativeNumbers(value), nil
+}
+
+func nativeNumbers(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = nativeNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = nativeNumbers(item)
+		}
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		n, _ := value.Float64()
+		return n
+	}
+	return value
+}
+
+func jsonPayload(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return json.Marshal(value)
+}
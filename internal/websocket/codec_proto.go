@@ -0,0 +1,182 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+
+	_websocket "github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoCodec encodes the gosocket.v1.Event and gosocket.v1.SubscribeEvent
+// messages of event.proto. Payloads travel as JSON bytes, a
+// google.protobuf.Value would turn every number into a double and round
+// integers above 2^53.
+type protoCodec struct{}
+
+const (
+	protoFieldType    protowire.Number = 1
+	protoFieldPayload protowire.Number = 2
+	protoFieldSeq     protowire.Number = 3
+	// SubscribeEvent uses field 3 for its trace context map.
+	protoFieldTraceContext protowire.Number = 3
+
+	protoFieldMapKey   protowire.Number = 1
+	protoFieldMapValue protowire.Number = 2
+)
+
+var errBadProtoMessage = errors.New("bad protobuf message")
+
+func (protoCodec) Name() string {
+	return CodecProto
+}
+
+func (protoCodec) MessageType() int {
+	return _websocket.BinaryMessage
+}
+
+func (protoCodec) EncodeEvent(event Event) ([]byte, error) {
+	var data []byte
+	data = protowire.AppendTag(data, protoFieldType, protowire.BytesType)
+	data = protowire.AppendString(data, event.Type)
+
+	data = appendProtoPayload(data, event.Payload)
+
+	if event.Seq != 0 {
+		data = protowire.AppendTag(data, protoFieldSeq, protowire.VarintType)
+		data = protowire.AppendVarint(data, uint64(event.Seq))
+	}
+	return data, nil
+}
+
+func (protoCodec) DecodeEvent(data []byte) (Event, error) {
+	var event Event
+	err := consumeProtoFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+		switch {
+		case num == protoFieldType && typ == protowire.BytesType:
+			value, n := protowire.ConsumeString(data)
+			event.Type = value
+			return n, nil
+		case num == protoFieldPayload && typ == protowire.BytesType:
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			if !json.Valid(value) {
+				return n, errBadProtoMessage
+			}
+			event.Payload = append(json.RawMessage(nil), value...)
+			return n, nil
+		case num == protoFieldSeq && typ == protowire.VarintType:
+			value, n := protowire.ConsumeVarint(data)
+			event.Seq = int64(value)
+			return n, nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, data), nil
+	})
+	return event, err
+}
+
+func (protoCodec) EncodeSubscribeEvent(event SubscribeEvent) ([]byte, error) {
+	var data []byte
+	data = protowire.AppendTag(data, protoFieldType, protowire.BytesType)
+	data = protowire.AppendString(data, event.Type)
+
+	data = appendProtoPayload(data, event.Payload)
+
+	for key, value := range event.TraceContext {
+		var entry []byte
+		entry = protowire.AppendTag(entry, protoFieldMapKey, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, protoFieldMapValue, protowire.BytesType)
+		entry = protowire.AppendString(entry, value)
+
+		data = protowire.AppendTag(data, protoFieldTraceContext, protowire.BytesType)
+		data = protowire.AppendBytes(data, entry)
+	}
+	return data, nil
+}
+
+func (protoCodec) DecodeSubscribeEvent(data []byte) (SubscribeEvent, error) {
+	var event SubscribeEvent
+	err := consumeProtoFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+		switch {
+		case num == protoFieldType && typ == protowire.BytesType:
+			value, n := protowire.ConsumeString(data)
+			event.Type = value
+			return n, nil
+		case num == protoFieldPayload && typ == protowire.BytesType:
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			if !json.Valid(value) {
+				return n, errBadProtoMessage
+			}
+			event.Payload = append(json.RawMessage(nil), value...)
+			return n, nil
+		case num == protoFieldTraceContext && typ == protowire.BytesType:
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return n, nil
+			}
+			key, entry, err := consumeProtoMapEntry(value)
+			if event.TraceContext == nil {
+				event.TraceContext = make(map[string]string)
+			}
+			event.TraceContext[key] = entry
+			return n, err
+		}
+		return protowire.ConsumeFieldValue(num, typ, data), nil
+	})
+	return event, err
+}
+
+// consumeProtoFields calls consume for every field of the message, consume
+// returns the length of the field value or a negative protowire error.
+func consumeProtoFields(data []byte, consume func(num protowire.Number, typ protowire.Type, data []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return errBadProtoMessage
+		}
+		data = data[n:]
+
+		n, err := consume(num, typ, data)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errBadProtoMessage
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func consumeProtoMapEntry(data []byte) (string, string, error) {
+	var key, value string
+	err := consumeProtoFields(data, func(num protowire.Number, typ protowire.Type, data []byte) (int, error) {
+		if typ != protowire.BytesType || (num != protoFieldMapKey && num != protoFieldMapValue) {
+			return protowire.ConsumeFieldValue(num, typ, data), nil
+		}
+
+		field, n := protowire.ConsumeString(data)
+		if num == protoFieldMapKey {
+			key = field
+		} else {
+			value = field
+		}
+		return n, nil
+	})
+	return key, value, err
+}
+
+func appendProtoPayload(data []byte, payload json.RawMessage) []byte {
+	if len(payload) == 0 {
+		return data
+	}
+
+	data = protowire.AppendTag(data, protoFieldPayload, protowire.BytesType)
+	return protowire.AppendBytes(data, payload)
+}
@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateCodec(t *testing.T) {
+	tests := []struct {
+		offered  string
+		codec    string
+		protocol string
+	}{
+		{"", CodecJSON, ""},
+		{"chat.msgpack", CodecMsgpack, "chat.msgpack"},
+		{"chat.unknown, chat.proto, chat.json", CodecProto, "chat.proto"},
+		{"bearer, token, chat.msgpack", CodecMsgpack, "chat.msgpack"},
+		{"bearer, token", CodecJSON, BearerSubprotocol},
+		{"graphql-ws", CodecJSON, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.offered, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws", nil)
+			if test.offered != "" {
+				r.Header.Set("Sec-WebSocket-Protocol", test.offered)
+			}
+
+			codec, protocol := negotiateCodec(r)
+			if codec.Name() != test.codec || protocol != test.protocol {
+				t.Errorf("got %s with %q, want %s with %q", codec.Name(), protocol, test.codec, test.protocol)
+			}
+		})
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	event := Event{
+		Type:    EventNewMessage,
+		Payload: json.RawMessage(`{"body":"hello","count":3,"ratio":0.5,"tags":["a","b"],"read":true,"thread":null}`),
+		Seq:     42,
+	}
+	subscribeEvent := SubscribeEvent{
+		Type:         SubscribeEventDeliver,
+		Payload:      json.RawMessage(`{"connection_ids":["a"]}`),
+		TraceContext: map[string]string{"traceparent": "00-1-2-01"},
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.EncodeEvent(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := codec.DecodeEvent(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Type != event.Type || decoded.Seq != event.Seq {
+				t.Errorf("got %+v, want %+v", decoded, event)
+			}
+			assertSamePayload(t, decoded.Payload, event.Payload)
+
+			data, err = codec.EncodeSubscribeEvent(subscribeEvent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decodedSubscribe, err := codec.DecodeSubscribeEvent(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decodedSubscribe.Type != subscribeEvent.Type || decodedSubscribe.TraceContext["traceparent"] != "00-1-2-01" {
+				t.Errorf("got %+v, want %+v", decodedSubscribe, subscribeEvent)
+			}
+			assertSamePayload(t, decodedSubscribe.Payload, subscribeEvent.Payload)
+		})
+	}
+}
+
+func assertSamePayload(t *testing.T, got json.RawMessage, want json.RawMessage) {
+	t.Helper()
+
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatal(err)
+	}
+
+	gotData, _ := json.Marshal(gotValue)
+	wantData, _ := json.Marshal(wantValue)
+	if string(gotData) != string(wantData) {
+		t.Errorf("got payload %s, want %s", got, want)
+	}
+}
+
+func TestCodecsKeepIntegerPrecision(t *testing.T) {
+	event := Event{Type: EventNewMessage, Payload: json.RawMessage(`{"id":9007199254740993}`)}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.EncodeEvent(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := codec.DecodeEvent(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var payload struct {
+				Id int64 `json:"id"`
+			}
+			if err := json.Unmarshal(decoded.Payload, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Id != 9007199254740993 {
+				t.Errorf("got %d, want 9007199254740993", payload.Id)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/observability"
+)
+
+// countingConn counts the bytes written to the hijacked connection, which
+// gives the size of frames after compression.
+type countingConn struct {
+	net.Conn
+	written atomic.Int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// countingResponseWriter hands a countingConn to the upgrader.
+type countingResponseWriter struct {
+	gin.ResponseWriter
+	conn *countingConn
+}
+
+func (w *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.conn = &countingConn{Conn: conn}
+	return w.conn, rw, nil
+}
+
+// offersCompression reports whether the upgrade request offers
+// permessage-deflate, the upgrader accepts it whenever it is offered.
+func offersCompression(r *http.Request) bool {
+	for _, extensions := range r.Header.Values("Sec-WebSocket-Extensions") {
+		if strings.Contains(extensions, "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFrame writes an encoded frame, compressing it when the connection
+// negotiated compression and the frame reaches the configured minimum size.
+func (c *Client) writeFrame(data []byte) error {
+	compress := c.compression && len(data) >= c.manager.config.SERVER.CompressionMinSize
+	c.connection.EnableWriteCompression(compress)
+
+	before := c.wire.written.Load()
+	err := c.connection.WriteMessage(c.codec.MessageType(), data)
+	observability.RecordFrame(compress, len(data), int(c.wire.written.Load()-before))
+	return err
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_websocket "github.com/gorilla/websocket"
+	"github.com/hiumesh/go-chat-server/internal/observability"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func frameBytes(compressed string, stage string) float64 {
+	return testutil.ToFloat64(observability.FrameBytes.WithLabelValues(compressed, stage))
+}
+
+func TestWriteFrameCompressesFramesAboveMinSize(t *testing.T) {
+	m := newTestManager(t)
+	m.config.SERVER.Compression = true
+	m.config.SERVER.CompressionMinSize = 512
+	m.upgrader.EnableCompression = true
+
+	small := []byte(`{"type":"typing"}`)
+	large := []byte(`{"type":"history","payload":"` + strings.Repeat("hello ", 1000) + `"}`)
+
+	written := make(chan struct{})
+	router := gin.New()
+	router.GET("/ws", func(ctx *gin.Context) {
+		writer := &countingResponseWriter{ResponseWriter: ctx.Writer}
+		conn, err := m.upgrader.Upgrade(writer, ctx.Request, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		c := &Client{
+			manager:     m,
+			connection:  conn,
+			wire:        writer.conn,
+			compression: m.config.SERVER.Compression && offersCompression(ctx.Request),
+			codec:       jsonCodec{},
+		}
+		for _, data := range [][]byte{small, large} {
+			if err := c.writeFrame(data); err != nil {
+				t.Error(err)
+			}
+		}
+		close(written)
+		conn.ReadMessage()
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	rawPlain, rawCompressed := frameBytes("false", "raw"), frameBytes("true", "raw")
+	wireCompressed := frameBytes("true", "wire")
+
+	dialer := _websocket.Dialer{EnableCompression: true}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	for _, want := range [][]byte{small, large} {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(want) {
+			t.Fatalf("got %d bytes, want %d", len(data), len(want))
+		}
+	}
+	<-written
+
+	if got := frameBytes("false", "raw") - rawPlain; got != float64(len(small)) {
+		t.Errorf("got %v uncompressed bytes, want the %d of the small frame", got, len(small))
+	}
+	if got := frameBytes("true", "raw") - rawCompressed; got != float64(len(large)) {
+		t.Errorf("got %v compressed raw bytes, want the %d of the large frame", got, len(large))
+	}
+	if got := frameBytes("true", "wire") - wireCompressed; got == 0 || got >= float64(len(large)) {
+		t.Errorf("got %v bytes on the wire for a %d bytes frame", got, len(large))
+	}
+}
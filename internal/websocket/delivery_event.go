@@ -0,0 +1,191 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const EventMessageAck = "message_ack"
+const EventDelivered = "delivered"
+const EventMessageDelivered = "message_delivered"
+
+type MessageAckEvent struct {
+	ClientMessageId string    `json:"client_message_id,omitempty"`
+	Id              string    `json:"id"`
+	ConversationId  string    `json:"conversation_id"`
+	Sent            time.Time `json:"sent"`
+}
+
+type DeliveredEvent struct {
+	Id string `json:"id"`
+}
+
+type MessageDeliveredEvent struct {
+	Id             string    `json:"id"`
+	ConversationId string    `json:"conversation_id"`
+	UserId         string    `json:"user_id"`
+	DeliveredAt    time.Time `json:"delivered_at"`
+}
+
+func clientMessageKey(userId string, clientMessageId string) string {
+	return "client_message:" + userId + ":" + clientMessageId
+}
+
+func pendingDeliveryKey(userId string) string {
+	return "pending:" + userId
+}
+
+// sentMessage is stored under the client message id of a send. Ack is only
+// set once every step of the send completed.
+type sentMessage struct {
+	MessageId string          `json:"message_id"`
+	Ack       json.RawMessage `json:"ack,omitempty"`
+}
+
+// sentMessage returns what an earlier send with the client message id
+// completed, nil when there was none.
+func (m *Manager) sentMessage(ctx context.Context, c *Client, clientMessageId string) (*sentMessage, error) {
+	data, err := m.rdb.Get(ctx, clientMessageKey(c.claims().Subject, clientMessageId)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sent sentMessage
+	if err := json.Unmarshal(data, &sent); err != nil {
+		return nil, err
+	}
+	return &sent, nil
+}
+
+func (m *Manager) rememberSentMessage(ctx context.Context, c *Client, clientMessageId string, sent sentMessage) error {
+	if clientMessageId == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(sent)
+	if err != nil {
+		return err
+	}
+	return m.rdb.Set(ctx, clientMessageKey(c.claims().Subject, clientMessageId), data, m.config.SERVER.PendingDeliveryTTL).Err()
+}
+
+// ackMessage confirms to the sending connection that the message has been
+// persisted and delivered, and remembers the acknowledgement so a retried
+// send with the same client message id is acknowledged again instead of
+// being stored twice.
+func (m *Manager) ackMessage(ctx context.Context, c *Client, clientMessageId string, message NewMessageEvent) error {
+	data, err := json.Marshal(MessageAckEvent{
+		ClientMessageId: clientMessageId,
+		Id:              message.Id,
+		ConversationId:  message.ConversationId,
+		Sent:            message.Sent,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := m.rememberSentMessage(ctx, c, clientMessageId, sentMessage{MessageId: message.Id, Ack: data}); err != nil {
+		return err
+	}
+
+	c.enqueue(Event{Type: EventMessageAck, Payload: data})
+	return nil
+}
+
+// trackPendingDelivery keeps the event until the recipient confirms it with a
+// delivered event, so it can be redelivered when the recipient reconnects.
+func (m *Manager) trackPendingDelivery(ctx context.Context, userId string, messageId string, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	key := pendingDeliveryKey(userId)
+	_, err = m.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, messageId, data)
+		pipe.Expire(ctx, key, m.config.SERVER.PendingDeliveryTTL)
+		return nil
+	})
+	return err
+}
+
+func (c *Client) redeliverPending(ctx *gin.Context) {
+	pending, err := c.manager.rdb.HGetAll(ctx, pendingDeliveryKey(c.claims().Subject)).Result()
+	if err != nil {
+		logrus.Errorf("error loading pending deliveries: %v", err)
+		return
+	}
+
+	for _, data := range pending {
+		var event Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			logrus.Errorf("error unmarshalling pending delivery: %v", err)
+			continue
+		}
+		if !c.enqueueWait(ctx, event) {
+			return
+		}
+	}
+}
+
+func DeliveredHandler(ctx context.Context, event Event, c *Client) error {
+	manager := c.manager
+	var deliveredEvent DeliveredEvent
+	if err := json.Unmarshal(event.Payload, &deliveredEvent); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	key := pendingDeliveryKey(c.claims().Subject)
+	data, err := manager.rdb.HGet(ctx, key, deliveredEvent.Id).Bytes()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	deleted, err := manager.rdb.HDel(ctx, key, deliveredEvent.Id).Result()
+	if err != nil || deleted == 0 {
+		return err
+	}
+
+	var pendingEvent Event
+	if err := json.Unmarshal(data, &pendingEvent); err != nil {
+		return err
+	}
+
+	// new_message carries the sender as from, thread replies as user_id
+	var message struct {
+		Id             string `json:"id"`
+		ConversationId string `json:"conversation_id"`
+		From           string `json:"from"`
+		UserId         string `json:"user_id"`
+	}
+	if err := json.Unmarshal(pendingEvent.Payload, &message); err != nil {
+		return err
+	}
+	if message.From == "" {
+		message.From = message.UserId
+	}
+
+	payload, err := json.Marshal(MessageDeliveredEvent{
+		Id:             message.Id,
+		ConversationId: message.ConversationId,
+		UserId:         c.claims().Subject,
+		DeliveredAt:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return manager.deliverToUsers(ctx, []string{message.From}, Event{Type: EventMessageDelivered, Payload: payload})
+}
@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+func TestSendMessageAcksAfterDelivery(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	registerTestClient(t, bob)
+	ctx := context.Background()
+
+	send := directMessageEvent(t, SendDirectMessageEvent{ClientMessageId: "1", To: "bob", Body: "hello"})
+	if err := SendMessageHandler(ctx, send, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	var ack MessageAckEvent
+	if err := json.Unmarshal(nextEventOfType(t, alice, EventMessageAck).Payload, &ack); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, bob, time.Second); event.Type != EventNewMessage {
+		t.Fatalf("got %q, want %q", event.Type, EventNewMessage)
+	}
+	if pending, err := m.rdb.HExists(ctx, pendingDeliveryKey("bob"), ack.Id).Result(); err != nil || !pending {
+		t.Errorf("message not tracked for redelivery: %v", err)
+	}
+
+	// An acknowledged send is only acknowledged again.
+	if err := SendMessageHandler(ctx, send, alice); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEventOfType(t, alice, EventMessageAck); string(event.Payload) == "" {
+		t.Error("no ack repeated")
+	}
+	select {
+	case event := <-bob.egress:
+		t.Errorf("acknowledged send delivered again as %q", event.Type)
+	default:
+	}
+}
+
+func TestRetriedSendCompletesInterruptedSend(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	registerTestClient(t, bob)
+	ctx := context.Background()
+
+	// The first attempt stored the message and failed before delivering it.
+	message := &models.Message{
+		ConversationId: models.DirectConversationId("alice", "bob"),
+		UserId:         "alice",
+		Body:           "hello",
+	}
+	if err := m.store.InsertMessage(message); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.rememberSentMessage(ctx, alice, "1", sentMessage{MessageId: message.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	send := directMessageEvent(t, SendDirectMessageEvent{ClientMessageId: "1", To: "bob", Body: "hello"})
+	if err := SendMessageHandler(ctx, send, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	var delivered NewMessageEvent
+	if err := json.Unmarshal(nextEvent(t, bob, time.Second).Payload, &delivered); err != nil {
+		t.Fatal(err)
+	}
+	if delivered.Id != message.Id {
+		t.Errorf("delivered %v, want the stored message %v", delivered.Id, message.Id)
+	}
+	if event := nextEventOfType(t, alice, EventMessageAck); event.Type != EventMessageAck {
+		t.Fatal("no ack")
+	}
+
+	messages, _, err := m.store.ListConversationMessages(message.ConversationId, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Errorf("got %d stored messages, want 1", len(messages))
+	}
+}
+
+func TestThreadRepliesAreRedelivered(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	registerTestClient(t, alice)
+	ctx := context.Background()
+
+	root := &models.Message{
+		ConversationId: models.DirectConversationId("alice", "bob"),
+		UserId:         "bob",
+		Body:           "root",
+	}
+	recordTestMessage(t, m, root, "alice", "bob")
+
+	send := directMessageEvent(t, SendDirectMessageEvent{ThreadRootId: root.Id, To: "bob", Body: "reply"})
+	if err := SendMessageHandler(ctx, send, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	var ack MessageAckEvent
+	if err := json.Unmarshal(nextEventOfType(t, alice, EventMessageAck).Payload, &ack); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := m.rdb.HExists(ctx, pendingDeliveryKey("bob"), ack.Id).Result(); err != nil || !pending {
+		t.Fatalf("reply not tracked for redelivery: %v", err)
+	}
+
+	data, err := json.Marshal(DeliveredEvent{Id: ack.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DeliveredHandler(ctx, Event{Type: EventDelivered, Payload: data}, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	var receipt MessageDeliveredEvent
+	if err := json.Unmarshal(nextEventOfType(t, alice, EventMessageDelivered).Payload, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Id != ack.Id || receipt.UserId != "bob" {
+		t.Errorf("got %+v, want the delivery of %v to bob", receipt, ack.Id)
+	}
+}
@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+)
+
+const EventEditMessage = "edit_message"
+const EventDeleteMessage = "delete_message"
+const EventMessageUpdated = "message_updated"
+const EventMessageDeleted = "message_deleted"
+
+var ErrNotMessageOwner = errors.New("only the sender can change a message")
+var ErrMessageDeleted = errors.New("message has been deleted")
+var ErrNotConversationParticipant = errors.New("user is not a participant of the conversation")
+
+type EditMessageEvent struct {
+	Id   string `json:"id"`
+	Body string `json:"body"`
+}
+
+type DeleteMessageEvent struct {
+	Id string `json:"id"`
+}
+
+type MessageDeletedEvent struct {
+	Id             string    `json:"id"`
+	ConversationId string    `json:"conversation_id"`
+	ChannelId      string    `json:"channel_id,omitempty"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
+
+// messageParticipants returns every participant of the conversation the
+// message belongs to, failing when the caller is not one of them.
+func (m *Manager) messageParticipants(claims *utils.AccessTokenClaims, message *models.Message) ([]string, error) {
+	if message.ChannelId != "" {
+		_, participantIds, err := m.conversationParticipants(claims, message.ChannelId, "")
+		return participantIds, err
+	}
+
+	conversation, err := m.store.GetUserConversation(claims.Subject, message.ConversationId)
+	if err != nil {
+		return nil, err
+	}
+	if conversation == nil {
+		return nil, ErrNotConversationParticipant
+	}
+	return []string{claims.Subject, conversation.PeerId}, nil
+}
+
+// ownedMessage loads a message the caller is allowed to change along with the
+// participants to notify about the change.
+func (m *Manager) ownedMessage(claims *utils.AccessTokenClaims, id string) (*models.Message, []string, error) {
+	message, err := m.store.GetMessage(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message.UserId != claims.Subject {
+		return nil, nil, ErrNotMessageOwner
+	}
+	if message.DeletedAt != nil {
+		return nil, nil, ErrMessageDeleted
+	}
+
+	participantIds, err := m.messageParticipants(claims, message)
+	if err != nil {
+		return nil, nil, err
+	}
+	return message, participantIds, nil
+}
+
+func (m *Manager) EditMessage(ctx context.Context, claims *utils.AccessTokenClaims, id string, body string) (*models.Message, error) {
+	message, participantIds, err := m.ownedMessage(claims, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.store.EditMessage(message, body); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.deliverToUsers(ctx, participantIds, Event{Type: EventMessageUpdated, Payload: data}); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (m *Manager) DeleteMessage(ctx context.Context, claims *utils.AccessTokenClaims, id string) (*models.Message, error) {
+	message, participantIds, err := m.ownedMessage(claims, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.store.DeleteMessage(message); err != nil {
+		return nil, err
+	}
+
+	// Deleted replies no longer count in the summary of their root.
+	var root *models.Message
+	if message.ThreadRootId != "" {
+		if root, err = m.store.GetMessage(message.ThreadRootId); err != nil {
+			return nil, err
+		}
+		if err := m.store.UpdateThreadSummary(root, message); err != nil {
+			return nil, err
+		}
+	}
+
+	if message.ChannelId == "" {
+		for _, participantId := range participantIds {
+			if err := m.rdb.HDel(ctx, pendingDeliveryKey(participantId), message.Id).Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	data, err := json.Marshal(MessageDeletedEvent{
+		Id:             message.Id,
+		ConversationId: message.ConversationId,
+		ChannelId:      message.ChannelId,
+		DeletedAt:      *message.DeletedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.deliverToUsers(ctx, participantIds, Event{Type: EventMessageDeleted, Payload: data}); err != nil {
+		return nil, err
+	}
+	if root != nil {
+		if err := m.deliverThreadSummary(ctx, root, participantIds); err != nil {
+			return nil, err
+		}
+	}
+	return message, nil
+}
+
+// ListMessageEdits returns the previous bodies of a message the caller can
+// see, newest first. The history of a deleted message is hidden with it.
+func (m *Manager) ListMessageEdits(claims *utils.AccessTokenClaims, id string) ([]models.MessageEdit, error) {
+	message, err := m.store.GetMessage(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := m.messageParticipants(claims, message); err != nil {
+		return nil, err
+	}
+	if message.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+
+	return m.store.ListMessageEdits(message.Id)
+}
+
+func EditMessageHandler(ctx context.Context, event Event, c *Client) error {
+	var editEvent EditMessageEvent
+	if err := json.Unmarshal(event.Payload, &editEvent); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	_, err := c.manager.EditMessage(ctx, c.claims(), editEvent.Id, editEvent.Body)
+	return err
+}
+
+func DeleteMessageHandler(ctx context.Context, event Event, c *Client) error {
+	var deleteEvent DeleteMessageEvent
+	if err := json.Unmarshal(event.Payload, &deleteEvent); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	_, err := c.manager.DeleteMessage(ctx, c.claims(), deleteEvent.Id)
+	return err
+}
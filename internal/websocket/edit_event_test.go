@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+func TestEditAndDeleteRequireTheSender(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	registerTestClient(t, bob)
+	ctx := context.Background()
+
+	message := &models.Message{ConversationId: models.DirectConversationId("alice", "bob"), UserId: "alice", Body: "hello"}
+	recordTestMessage(t, m, message, "alice", "bob")
+
+	if _, err := m.EditMessage(ctx, bob.claims(), message.Id, "changed"); !errors.Is(err, ErrNotMessageOwner) {
+		t.Errorf("got %v editing as bob, want %v", err, ErrNotMessageOwner)
+	}
+	if _, err := m.DeleteMessage(ctx, bob.claims(), message.Id); !errors.Is(err, ErrNotMessageOwner) {
+		t.Errorf("got %v deleting as bob, want %v", err, ErrNotMessageOwner)
+	}
+
+	edited, err := m.EditMessage(ctx, alice.claims(), message.Id, "hello again")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Body != "hello again" || edited.UpdatedAt == nil {
+		t.Errorf("got %+v, want the edited body", edited)
+	}
+	if event := nextEvent(t, bob, time.Second); event.Type != EventMessageUpdated {
+		t.Errorf("got %q, want %q", event.Type, EventMessageUpdated)
+	}
+
+	// Both participants see the previous bodies, nobody else does.
+	edits, err := m.ListMessageEdits(bob.claims(), message.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 1 || edits[0].Body != "hello" {
+		t.Errorf("got edits %+v, want the original body", edits)
+	}
+	if _, err := m.ListMessageEdits(testClaims("carol", time.Hour), message.Id); !errors.Is(err, ErrNotConversationParticipant) {
+		t.Errorf("got %v listing edits as carol, want %v", err, ErrNotConversationParticipant)
+	}
+
+	deleted, err := m.DeleteMessage(ctx, alice.claims(), message.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Body != "" || deleted.DeletedAt == nil {
+		t.Errorf("got %+v, want a tombstone", deleted)
+	}
+	if event := nextEvent(t, bob, time.Second); event.Type != EventMessageDeleted {
+		t.Errorf("got %q, want %q", event.Type, EventMessageDeleted)
+	}
+
+	if _, err := m.EditMessage(ctx, alice.claims(), message.Id, "too late"); !errors.Is(err, ErrMessageDeleted) {
+		t.Errorf("got %v editing a deleted message, want %v", err, ErrMessageDeleted)
+	}
+	if _, err := m.ListMessageEdits(alice.claims(), message.Id); !errors.Is(err, ErrMessageDeleted) {
+		t.Errorf("got %v listing the edits of a deleted message, want %v", err, ErrMessageDeleted)
+	}
+}
@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hiumesh/go-chat-server/internal/observability"
+	"github.com/sirupsen/logrus"
+)
+
+// CloseSlowConsumer is sent to connections whose egress queue overflows with
+// the disconnect policy.
+const CloseSlowConsumer = 4008
+
+const (
+	EgressOverflowDropOldest = "drop_oldest"
+	EgressOverflowDropNewest = "drop_newest"
+	EgressOverflowDisconnect = "disconnect"
+)
+
+// enqueueWait queues the event, waiting for room instead of applying the
+// overflow policy, for events that must not be dropped. It gives up and
+// returns false once the client is removed or the context is done.
+func (c *Client) enqueueWait(ctx context.Context, event Event) bool {
+	observability.EgressQueueDepth.Observe(float64(len(c.egress)))
+
+	select {
+	case c.egress <- event:
+		return true
+	case <-c.done:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// enqueue queues the event for the writer without ever blocking the caller,
+// applying the configured overflow policy when the queue is full.
+func (c *Client) enqueue(event Event) {
+	observability.EgressQueueDepth.Observe(float64(len(c.egress)))
+
+	select {
+	case c.egress <- event:
+		return
+	default:
+	}
+
+	policy := c.manager.config.SERVER.EgressOverflow
+	c.overflows.Add(1)
+	observability.EgressOverflows.WithLabelValues(policy).Inc()
+	logrus.Warnf("egress queue full for connection %v, applying %s", c.connectionId, policy)
+
+	switch policy {
+	case EgressOverflowDropNewest:
+	case EgressOverflowDisconnect:
+		go c.closeWithCode(CloseSlowConsumer, "slow consumer")
+	default:
+		select {
+		case <-c.egress:
+		default:
+		}
+		select {
+		case c.egress <- event:
+		default:
+		}
+	}
+}
+
+// EgressStats describes the egress queue of a local connection.
+type EgressStats struct {
+	ConnectionId string `json:"connection_id"`
+	UserId       string `json:"user_id"`
+	Queued       int    `json:"queued"`
+	Capacity     int    `json:"capacity"`
+	// Overflows counts the events the overflow policy was applied to.
+	Overflows int64 `json:"overflows"`
+}
+
+// EgressStats returns the egress queues of the local connections, fullest
+// first.
+func (m *Manager) EgressStats() []EgressStats {
+	m.RLock()
+	stats := make([]EgressStats, 0, len(m.clients))
+	for _, client := range m.clients {
+		stats = append(stats, EgressStats{
+			ConnectionId: client.connectionId,
+			UserId:       client.claims().Subject,
+			Queued:       len(client.egress),
+			Capacity:     cap(client.egress),
+			Overflows:    client.overflows.Load(),
+		})
+	}
+	m.RUnlock()
+
+	sort.Slice(stats, func(a, b int) bool {
+		if stats[a].Queued != stats[b].Queued {
+			return stats[a].Queued > stats[b].Queued
+		}
+		return stats[a].Overflows > stats[b].Overflows
+	})
+	return stats
+}
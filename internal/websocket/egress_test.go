@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func fillEgress(c *Client, count int) {
+	for i := 0; i < count; i++ {
+		c.enqueue(Event{Type: EventNewMessage, Seq: int64(i + 1)})
+	}
+}
+
+func queuedSeqs(c *Client) []int64 {
+	var seqs []int64
+	for {
+		select {
+		case event := <-c.egress:
+			seqs = append(seqs, event.Seq)
+		default:
+			return seqs
+		}
+	}
+}
+
+func TestEgressOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []int64
+	}{
+		{EgressOverflowDropOldest, []int64{3, 4, 5, 6}},
+		{EgressOverflowDropNewest, []int64{1, 2, 3, 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			m := newTestManager(t)
+			m.config.SERVER.EgressOverflow = test.policy
+			c := newTestClient(t, m, testClaims("alice", time.Hour))
+
+			fillEgress(c, 6)
+
+			got := queuedSeqs(c)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("got %v, want %v", got, test.want)
+				}
+			}
+			if overflows := c.overflows.Load(); overflows != 2 {
+				t.Errorf("got %d overflows, want 2", overflows)
+			}
+		})
+	}
+}
+
+func TestEgressOverflowDisconnects(t *testing.T) {
+	m := newTestManager(t)
+	m.config.SERVER.EgressOverflow = EgressOverflowDisconnect
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+
+	fillEgress(c, 5)
+
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+		t.Fatal("client not closed")
+	}
+	if c.closeCode != CloseSlowConsumer {
+		t.Errorf("got close code %d, want %d", c.closeCode, CloseSlowConsumer)
+	}
+}
+
+func TestEgressStatsListsFullestFirst(t *testing.T) {
+	m := newTestManager(t)
+	idle := newTestClient(t, m, testClaims("alice", time.Hour))
+	busy := newTestClient(t, m, testClaims("bob", time.Hour))
+
+	fillEgress(busy, 5)
+
+	stats := m.EgressStats()
+	if len(stats) != 2 {
+		t.Fatalf("got %d connections, want 2", len(stats))
+	}
+	if stats[0].ConnectionId != busy.connectionId || stats[0].Queued != 4 || stats[0].Overflows != 1 {
+		t.Errorf("got %+v first, want the full queue of %v", stats[0], busy.connectionId)
+	}
+	if stats[1].ConnectionId != idle.connectionId || stats[1].Capacity != 4 {
+		t.Errorf("got %+v last, want the empty queue of %v", stats[1], idle.connectionId)
+	}
+}
@@ -1,77 +1,139 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/hiumesh/go-chat-server/internal/models"
-	"github.com/hiumesh/go-chat-server/internal/utils"
 )
 
 type Event struct {
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload"`
+	Seq     int64           `json:"seq,omitempty"`
 }
 
-type EventHandler func(ginCtx *gin.Context, event Event, c *Client) error
+type EventHandler func(ctx context.Context, event Event, c *Client) error
 
 const EventSendDirectMessage = "direct_message"
 const EventNewMessage = "new_message"
 const EventChangeRoom = "change_room"
 
 type SendDirectMessageEvent struct {
-	Body string `json:"body"`
-	From string `json:"from"`
-	To   string `json:"to"`
+	ClientMessageId string `json:"client_message_id,omitempty"`
+	ThreadRootId    string `json:"thread_root_id,omitempty"`
+	Body            string `json:"body"`
+	From            string `json:"from"`
+	To              string `json:"to"`
 }
 
 type NewMessageEvent struct {
 	SendDirectMessageEvent
-	Sent time.Time `json:"sent"`
+	Id             string    `json:"id"`
+	ConversationId string    `json:"conversation_id"`
+	Sent           time.Time `json:"sent"`
 }
 
-func SendMessageHandler(ctx *gin.Context, event Event, c *Client) error {
+func SendMessageHandler(ctx context.Context, event Event, c *Client) error {
 	manager := c.manager
 	var chatevent SendDirectMessageEvent
 	if err := json.Unmarshal(event.Payload, &chatevent); err != nil {
 		return errors.New("bad payload in request")
 	}
 
-	claims := utils.GetClaims(ctx)
+	claims := c.claims()
 
 	if claims == nil {
 		return errors.New("claims not found")
 	}
 
-	senderUserActiveConnections, err := manager.rdb.ZRange(ctx, claims.Subject, 0, -1).Result()
-	if err != nil {
-		return err
+	// A retried send finishes the steps the first attempt did not complete
+	// instead of storing the message twice.
+	var dbMessage *models.Message
+	if chatevent.ClientMessageId != "" {
+		sent, err := manager.sentMessage(ctx, c, chatevent.ClientMessageId)
+		if err != nil {
+			return err
+		}
+		if sent != nil && sent.Ack != nil {
+			c.enqueue(Event{Type: EventMessageAck, Payload: sent.Ack})
+			return nil
+		}
+		if sent != nil {
+			if dbMessage, err = manager.store.GetMessage(sent.MessageId); err != nil {
+				return err
+			}
+			if dbMessage.ConversationId != models.DirectConversationId(claims.Subject, chatevent.To) {
+				return errors.New("client message id already used in another conversation")
+			}
+		}
 	}
-	reciverUserActiveConnections, err := manager.rdb.ZRange(ctx, chatevent.To, 0, -1).Result()
-	if err != nil {
-		return err
+
+	if dbMessage == nil {
+		dbMessage = &models.Message{
+			ConversationId: models.DirectConversationId(claims.Subject, chatevent.To),
+			ThreadRootId:   chatevent.ThreadRootId,
+			UserId:         claims.Subject,
+			Body:           chatevent.Body,
+		}
 	}
+	participantIds := []string{chatevent.To, claims.Subject}
 
-	dbMessage := models.Message{
-		UserId: claims.Subject,
-		Body:   chatevent.Body,
+	var root *models.Message
+	if dbMessage.ThreadRootId != "" {
+		var err error
+		if root, err = manager.validateThreadReply(claims, dbMessage); err != nil {
+			return err
+		}
 	}
 
-	if err := models.InsertMessage(manager.db, &dbMessage); err != nil {
-		return err
+	if dbMessage.Id == "" {
+		var err error
+		if root != nil {
+			// Counted with the insert, a retry loads the stored reply and
+			// does not count it again.
+			err = manager.insertThreadReply(ctx, root, dbMessage)
+		} else {
+			err = manager.insertMessage(ctx, dbMessage)
+		}
+		if err != nil {
+			return err
+		}
+		if err := manager.rememberSentMessage(ctx, c, chatevent.ClientMessageId, sentMessage{MessageId: dbMessage.Id}); err != nil {
+			return err
+		}
 	}
 
 	var broadMessage NewMessageEvent
 
-	broadMessage.Sent = time.Now()
-	broadMessage.Body = chatevent.Body
-	broadMessage.From = claims.Id
+	broadMessage.ClientMessageId = chatevent.ClientMessageId
+	broadMessage.ThreadRootId = dbMessage.ThreadRootId
+	broadMessage.Id = dbMessage.Id
+	broadMessage.ConversationId = dbMessage.ConversationId
+	broadMessage.Sent = dbMessage.CreatedAt
+	broadMessage.Body = dbMessage.Body
+	broadMessage.From = claims.Subject
 	broadMessage.To = chatevent.To
 
+	// The ack is only stored once the message is tracked and delivered, a
+	// retry before that redoes the steps below.
+	if root != nil {
+		data, err := json.Marshal(dbMessage)
+		if err != nil {
+			return err
+		}
+		if err := manager.trackPendingDelivery(ctx, chatevent.To, dbMessage.Id, Event{Type: EventNewThreadMessage, Payload: data}); err != nil {
+			return err
+		}
+		if err := manager.deliverThreadReply(ctx, root, dbMessage, participantIds); err != nil {
+			return err
+		}
+		return manager.ackMessage(ctx, c, chatevent.ClientMessageId, broadMessage)
+	}
+
 	data, err := json.Marshal(broadMessage)
 	if err != nil {
 		return err
@@ -81,36 +143,27 @@ func SendMessageHandler(ctx *gin.Context, event Event, c *Client) error {
 	outgoingEvent.Payload = data
 	outgoingEvent.Type = EventNewMessage
 
-	for _, connectionStr := range reciverUserActiveConnections {
-		split := strings.Split(connectionStr, " ")
-		serverId := split[0]
-		connectionId := split[1]
-
-		if manager.config.SERVER.Id == serverId {
-			connection := c.manager.clients[connectionId]
-			connection.egress <- outgoingEvent
-		} else {
-			manager.rdb.Publish(ctx, serverId, event)
-		}
+	if err := manager.trackPendingDelivery(ctx, chatevent.To, broadMessage.Id, outgoingEvent); err != nil {
+		return err
 	}
 
-	for _, connectionStr := range senderUserActiveConnections {
-		split := strings.Split(connectionStr, " ")
-		serverId := split[0]
-		connectionId := split[1]
-
-		println(connectionId, serverId)
+	if err := manager.deliverToUsers(ctx, participantIds, outgoingEvent); err != nil {
+		return err
 	}
 
-	return nil
+	// Counted last, it is the only step a retry must not repeat.
+	if err := manager.store.RecordConversationMessage(dbMessage, participantIds); err != nil {
+		return err
+	}
 
+	return manager.ackMessage(ctx, c, chatevent.ClientMessageId, broadMessage)
 }
 
 type ChangeRoomEvent struct {
 	Name string `json:"name"`
 }
 
-func ChatRoomHandler(ginCtx *gin.Context, event Event, c *Client) error {
+func ChatRoomHandler(ctx context.Context, event Event, c *Client) error {
 
 	var changeRoomEvent ChangeRoomEvent
 	if err := json.Unmarshal(event.Payload, &changeRoomEvent); err != nil {
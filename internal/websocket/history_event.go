@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+)
+
+const EventFetchHistory = "fetch_history"
+const EventHistory = "history"
+
+const defaultHistoryLimit = 50
+const maxHistoryLimit = 100
+
+var ErrConversationRequired = errors.New("either channel_id or user_id is required")
+
+type FetchHistoryEvent struct {
+	ChannelId string `json:"channel_id,omitempty"`
+	UserId    string `json:"user_id,omitempty"`
+	Cursor    string `json:"cursor,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+}
+
+type HistoryEvent struct {
+	ConversationId string           `json:"conversation_id"`
+	ChannelId      string           `json:"channel_id,omitempty"`
+	UserId         string           `json:"user_id,omitempty"`
+	Messages       []models.Message `json:"messages"`
+	NextCursor     string           `json:"next_cursor,omitempty"`
+}
+
+// FetchHistory returns a page of the conversation identified by either a
+// channel or the other participant of a direct conversation, newest-first.
+func (m *Manager) FetchHistory(claims *utils.AccessTokenClaims, request FetchHistoryEvent) (*HistoryEvent, error) {
+	conversationId, err := m.resolveConversation(claims, request.ChannelId, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	messages, nextCursor, err := m.store.ListConversationMessages(conversationId, request.Cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HistoryEvent{
+		ConversationId: conversationId,
+		ChannelId:      request.ChannelId,
+		UserId:         request.UserId,
+		Messages:       messages,
+		NextCursor:     nextCursor,
+	}, nil
+}
+
+func (m *Manager) resolveConversation(claims *utils.AccessTokenClaims, channelId string, userId string) (string, error) {
+	switch {
+	case channelId != "":
+		isMember, err := m.store.IsChannelUser(channelId, claims.Subject)
+		if err != nil {
+			return "", err
+		}
+		if !isMember {
+			return "", ErrNotChannelMember
+		}
+		return channelId, nil
+	case userId != "":
+		return models.DirectConversationId(claims.Subject, userId), nil
+	default:
+		return "", ErrConversationRequired
+	}
+}
+
+func FetchHistoryHandler(ctx context.Context, event Event, c *Client) error {
+	var request FetchHistoryEvent
+	if err := json.Unmarshal(event.Payload, &request); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	history, err := c.manager.FetchHistory(c.claims(), request)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	c.enqueue(Event{Type: EventHistory, Payload: data})
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+func TestFetchHistoryPagesNewestFirst(t *testing.T) {
+	m := newTestManager(t)
+	claims := testClaims("alice", time.Hour)
+
+	conversationId := models.DirectConversationId("alice", "bob")
+	var ids []string
+	for i := 0; i < 5; i++ {
+		message := &models.Message{ConversationId: conversationId, UserId: "bob", Body: "hello"}
+		recordTestMessage(t, m, message, "alice", "bob")
+		ids = append(ids, message.Id)
+	}
+	// Replies are kept out of the conversation history.
+	recordTestMessage(t, m, &models.Message{ConversationId: conversationId, ThreadRootId: ids[0], UserId: "bob", Body: "reply"}, "alice", "bob")
+
+	var got []string
+	cursor := ""
+	for page := 0; ; page++ {
+		history, err := m.FetchHistory(claims, FetchHistoryEvent{UserId: "bob", Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if history.ConversationId != conversationId {
+			t.Fatalf("got conversation %v, want %v", history.ConversationId, conversationId)
+		}
+		for _, message := range history.Messages {
+			got = append(got, message.Id)
+		}
+		if history.NextCursor == "" {
+			break
+		}
+		if page > 3 {
+			t.Fatal("history does not end")
+		}
+		cursor = history.NextCursor
+	}
+
+	if len(got) != len(ids) {
+		t.Fatalf("got %d messages, want %d", len(got), len(ids))
+	}
+	for i := range got {
+		if got[i] != ids[len(ids)-1-i] {
+			t.Fatalf("got %v, want %v reversed", got, ids)
+		}
+	}
+}
+
+func TestFetchHistoryRequiresConversation(t *testing.T) {
+	m := newTestManager(t)
+	claims := testClaims("alice", time.Hour)
+
+	if _, err := m.FetchHistory(claims, FetchHistoryEvent{}); !errors.Is(err, ErrConversationRequired) {
+		t.Errorf("got %v, want %v", err, ErrConversationRequired)
+	}
+	if _, err := m.FetchHistory(claims, FetchHistoryEvent{ChannelId: "general"}); !errors.Is(err, ErrNotChannelMember) {
+		t.Errorf("got %v, want %v", err, ErrNotChannelMember)
+	}
+	if _, err := m.FetchHistory(claims, FetchHistoryEvent{UserId: "bob", Cursor: "not a cursor"}); !errors.Is(err, models.ErrInvalidCursor) {
+		t.Errorf("got %v, want %v", err, models.ErrInvalidCursor)
+	}
+}
@@ -5,108 +5,298 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_websocket "github.com/gorilla/websocket"
 	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/hiumesh/go-chat-server/internal/models"
+	"github.com/hiumesh/go-chat-server/internal/observability"
 	"github.com/hiumesh/go-chat-server/internal/utils"
 	"github.com/redis/go-redis/v9"
-	"github.com/scylladb/gocqlx/v2"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var websocketUpgrader = _websocket.Upgrader{
-	CheckOrigin:     checkOrigin,
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-}
+// BearerSubprotocol announces that the next subprotocol offered by the
+// client is its access token. It is the one selected on the upgrade.
+const BearerSubprotocol = "bearer"
+
 var ErrEventNotSupported = errors.New("this event type is not supported")
 
 type Manager struct {
 	config            *conf.GlobalConfiguration `required:"true"`
 	rdb               *redis.Client             `required:"true"`
-	db                gocqlx.Session            `required:"true"`
+	store             models.Store              `required:"true"`
+	registry          ConnectionRegistry        `required:"true"`
+	tokenParser       *utils.TokenParser        `required:"true"`
+	revoker           *Revoker
+	upgrader          _websocket.Upgrader
+	bus               Bus
 	clients           ClientList
 	handlers          map[string]EventHandler
 	subscribeHandlers map[string]SubscribeEventHandler
 	sync.RWMutex
 }
 
-func NewManager(ctx context.Context, config *conf.GlobalConfiguration, redisDb *redis.Client, db gocqlx.Session) *Manager {
+func NewManager(ctx context.Context, config *conf.GlobalConfiguration, redisDb *redis.Client, store models.Store, registry ConnectionRegistry, bus Bus, tokenParser *utils.TokenParser) *Manager {
 	m := &Manager{
 		rdb:               redisDb,
-		db:                db,
+		store:             store,
+		registry:          registry,
+		bus:               bus,
+		tokenParser:       tokenParser,
 		config:            config,
 		clients:           make(ClientList),
 		handlers:          make(map[string]EventHandler),
 		subscribeHandlers: make(map[string]SubscribeEventHandler),
 	}
+	m.upgrader = _websocket.Upgrader{
+		CheckOrigin:       m.checkOrigin,
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: config.SERVER.Compression,
+	}
+	m.revoker = NewRevoker(redisDb, registry, bus, config.SERVER.RevocationTTL)
+	observability.RegisterEgressQueues(func() float64 {
+		total, _ := m.egressDepths()
+		return float64(total)
+	}, func() float64 {
+		_, max := m.egressDepths()
+		return float64(max)
+	})
 	m.setupEventHandlers()
 	m.setupSubscribeEventHandlers()
-	go m.setupAndListenRedisSubscriber()
+	go m.setupAndListenBus(ctx)
 	return m
 }
 
 func (m *Manager) setupEventHandlers() {
 	m.handlers[EventSendDirectMessage] = SendMessageHandler
+	m.handlers[EventSendChannelMessage] = SendChannelMessageHandler
+	m.handlers[EventFetchHistory] = FetchHistoryHandler
+	m.handlers[EventResume] = ResumeHandler
+	m.handlers[EventDelivered] = DeliveredHandler
+	m.handlers[EventMarkRead] = MarkReadHandler
+	m.handlers[EventTypingStart] = TypingStartHandler
+	m.handlers[EventTypingStop] = TypingStopHandler
+	m.handlers[EventSubscribePresence] = SubscribePresenceHandler
+	m.handlers[EventUnsubscribePresence] = UnsubscribePresenceHandler
+	m.handlers[EventSetPresence] = SetPresenceHandler
+	m.handlers[EventEditMessage] = EditMessageHandler
+	m.handlers[EventDeleteMessage] = DeleteMessageHandler
+	m.handlers[EventAddReaction] = AddReactionHandler
+	m.handlers[EventRemoveReaction] = RemoveReactionHandler
+	m.handlers[EventOpenThread] = OpenThreadHandler
+	m.handlers[EventCloseThread] = CloseThreadHandler
+	m.handlers[EventFetchThread] = FetchThreadHandler
+	m.handlers[EventRefreshToken] = RefreshTokenHandler
 }
 
 func (m *Manager) setupSubscribeEventHandlers() {
-	m.subscribeHandlers[SubscribeEventSendDirectMessage] = SubscribeEventSendDirectMessageHandler
+	m.subscribeHandlers[SubscribeEventDeliver] = SubscribeEventDeliverHandler
+	m.subscribeHandlers[SubscribeEventKill] = SubscribeEventKillHandler
 }
 
-func (m *Manager) setupAndListenRedisSubscriber() {
-	ctx := context.Background()
-	sub := m.rdb.Subscribe(ctx, m.config.SERVER.Id)
-	for {
-		msg, err := sub.ReceiveMessage(ctx)
-		if err != nil {
-			logrus.Fatalf("error on receeving subscribe message: %v", err)
-		}
-
-		var request SubscribeEvent
-		if err := json.Unmarshal([]byte(msg.Payload), &request); err != nil {
-			logrus.Errorf("error marshalling message: %v", err)
-			continue
+// setupAndListenBus subscribes to the bus until the context is done,
+// subscribing again with a backoff whenever the bus fails.
+func (m *Manager) setupAndListenBus(ctx context.Context) {
+	for attempt := 0; ; attempt++ {
+		err := m.bus.Subscribe(ctx, m.config.SERVER.Id, func(event SubscribeEvent) error {
+			logrus.Debugf("new subscribe event")
+			return m.routeSubscribeEvent(ctx, event)
+		})
+		if err == nil {
+			return
 		}
 
-		logrus.Debugf("new subscribe event")
-
-		if err := m.routeSubscribeEvent(request); err != nil {
-			logrus.Errorf("error handeling subscribe event: %v", err)
+		logrus.Errorf("error on receiving subscribe event: %v", err)
+		if !sleepBackoff(ctx, attempt) {
+			return
 		}
-
 	}
 }
 
-func (m *Manager) routeEvent(ginCtx *gin.Context, event Event, c *Client) error {
+func (m *Manager) routeEvent(ctx context.Context, event Event, c *Client) error {
+	ctx, span := observability.Tracer.Start(ctx, "event "+event.Type, trace.WithAttributes(
+		attribute.String("gosocket.connection_id", c.connectionId),
+		attribute.String("gosocket.user_id", c.claims().Subject),
+	))
+	defer span.End()
+
 	if handler, ok := m.handlers[event.Type]; ok {
-		if err := handler(ginCtx, event, c); err != nil {
+		observability.EventsHandled.WithLabelValues(event.Type).Inc()
+		if err := handler(ctx, event, c); err != nil {
+			observability.EventErrors.WithLabelValues(event.Type).Inc()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 			return err
 		}
 		return nil
 	} else {
+		observability.EventErrors.WithLabelValues("unsupported").Inc()
 		return ErrEventNotSupported
 	}
 }
 
-func (m *Manager) routeSubscribeEvent(event SubscribeEvent) error {
+func (m *Manager) routeSubscribeEvent(ctx context.Context, event SubscribeEvent) error {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(event.TraceContext))
+	_, span := observability.Tracer.Start(ctx, "bus.receive "+event.Type, trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+
 	if handler, ok := m.subscribeHandlers[event.Type]; ok {
+		observability.SubscribeEventsHandled.WithLabelValues(event.Type).Inc()
 		if err := handler(event, m); err != nil {
+			observability.SubscribeEventErrors.WithLabelValues(event.Type).Inc()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 			return err
 		}
 		return nil
 	} else {
+		observability.SubscribeEventErrors.WithLabelValues("unsupported").Inc()
 		return ErrEventNotSupported
 	}
 }
 
+// egressDepths returns the number of events waiting in the egress queues of
+// every local connection and in the fullest one.
+func (m *Manager) egressDepths() (int, int) {
+	m.RLock()
+	defer m.RUnlock()
+
+	total, max := 0, 0
+	for _, client := range m.clients {
+		depth := len(client.egress)
+		total += depth
+		if depth > max {
+			max = depth
+		}
+	}
+	return total, max
+}
+
+// insertMessage persists the message inside its own span.
+func (m *Manager) insertMessage(ctx context.Context, message *models.Message) error {
+	_, span := observability.Tracer.Start(ctx, "InsertMessage", trace.WithAttributes(
+		attribute.String("gosocket.conversation_id", message.ConversationId),
+	))
+	defer span.End()
+
+	if err := m.store.InsertMessage(message); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.SetAttributes(attribute.String("gosocket.message_id", message.Id))
+	return nil
+}
+
+// deliverToUsers stamps the event with each user's next sequence number and
+// pushes it to every active connection of the given users, publishing to the
+// owning server when the connection lives elsewhere.
+func (m *Manager) deliverToUsers(ctx context.Context, userIds []string, event Event) error {
+	seen := make(map[string]bool)
+
+	for _, userId := range userIds {
+		if seen[userId] {
+			continue
+		}
+		seen[userId] = true
+
+		userEvent, err := m.sequenceEvent(ctx, userId, event)
+		if err != nil {
+			return err
+		}
+
+		if err := m.deliverToUser(ctx, userId, userEvent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliverToUser pushes an event without sequence number, only for events that
+// are stale once missed, typing and presence changes, which are neither
+// buffered nor replayed. Replies to a connection's own requests are enqueued
+// on it directly.
+func (m *Manager) deliverToUser(ctx context.Context, userId string, event Event) error {
+	activeConnections, err := m.registry.Connections(ctx, userId, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	entries := make([]string, 0, len(activeConnections))
+	for _, connection := range activeConnections {
+		entries = append(entries, connection.Entry)
+	}
+	return m.deliverToConnections(ctx, entries, event)
+}
+
+// deliverToConnections pushes the event to connections given as registry
+// entries of the form "serverId connectionId".
+func (m *Manager) deliverToConnections(ctx context.Context, connections []string, event Event) error {
+	remoteConnections := make(map[string][]string)
+	for _, connectionStr := range connections {
+		serverId, connectionId, ok := strings.Cut(connectionStr, " ")
+		if !ok {
+			continue
+		}
+
+		if m.config.SERVER.Id == serverId {
+			m.deliverLocal(connectionId, event)
+		} else {
+			remoteConnections[serverId] = append(remoteConnections[serverId], connectionId)
+		}
+	}
+
+	for serverId, connectionIds := range remoteConnections {
+		data, err := json.Marshal(DeliverSubscribeEvent{ConnectionIds: connectionIds, Event: event})
+		if err != nil {
+			return err
+		}
+
+		if err := publish(ctx, m.bus, serverId, SubscribeEvent{Type: SubscribeEventDeliver, Payload: data}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) deliverLocal(connectionId string, event Event) {
+	m.RLock()
+	client, ok := m.clients[connectionId]
+	m.RUnlock()
+
+	if !ok {
+		return
+	}
+	if event.Seq != 0 {
+		client.deliverSequenced(event)
+	} else {
+		client.enqueue(event)
+	}
+}
+
+func (m *Manager) Revoker() *Revoker {
+	return m.revoker
+}
+
 func (m *Manager) addClient(client *Client) {
 	m.Lock()
 	defer m.Unlock()
 
 	m.clients[client.connectionId] = client
+	observability.ConnectedClients.Inc()
+	go client.fillGaps()
 }
 
 func (m *Manager) removeClient(client *Client) {
@@ -114,42 +304,74 @@ func (m *Manager) removeClient(client *Client) {
 	defer m.Unlock()
 
 	if _, ok := m.clients[client.connectionId]; ok {
-		client.connection.Close()
+		if client.connection != nil {
+			client.connection.Close()
+		}
+		close(client.done)
 		delete(m.clients, client.connectionId)
+		observability.ConnectedClients.Dec()
 	}
 }
 
 func (m *Manager) ServeWS(ginCtx *gin.Context) {
-	conn, err := websocketUpgrader.Upgrade(ginCtx.Writer, ginCtx.Request, nil)
+	_, span := observability.Tracer.Start(ginCtx.Request.Context(), "websocket.upgrade", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	codec, protocol := negotiateCodec(ginCtx.Request)
+	header := http.Header{}
+	if protocol != "" {
+		header.Set("Sec-WebSocket-Protocol", protocol)
+	}
+	span.SetAttributes(attribute.String("gosocket.codec", codec.Name()))
+
+	writer := &countingResponseWriter{ResponseWriter: ginCtx.Writer}
+	conn, err := m.upgrader.Upgrade(writer, ginCtx.Request, header)
 
 	if err != nil {
-		conn.Close()
+		span.SetStatus(codes.Error, err.Error())
+		observability.UpgradeFailures.WithLabelValues("upgrade").Inc()
 		logrus.Errorf("failed to upgrage the connection: %+v", err)
 		utils.HandleHttpError(utils.InternalServerError("Failed to upgrage the connection: %+v", err), ginCtx)
 		return
 	}
 
-	client, err := NewClient(ginCtx, conn, m)
+	client, err := NewClient(ginCtx, conn, m, codec)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		observability.UpgradeFailures.WithLabelValues("setup").Inc()
 		conn.Close()
 		logrus.Errorf("failed to setup the connection: %+v", err)
 		utils.HandleHttpError(utils.InternalServerError("Failed to setup the connection: %+v", err), ginCtx)
 		return
 	}
 
+	client.wire = writer.conn
+	client.compression = m.config.SERVER.Compression && offersCompression(ginCtx.Request)
+	if err := conn.SetCompressionLevel(m.config.SERVER.CompressionLevel); err != nil {
+		logrus.Errorf("error setting the compression level: %v", err)
+	}
+
+	if err := m.revoker.trackSession(ginCtx, client.claims()); err != nil {
+		logrus.Errorf("error tracking the session: %v", err)
+	}
+
 	m.addClient(client)
+	client.scheduleExpiry()
+	m.userConnected(ginCtx, client.claims().Subject)
 
 	go client.readMessage(ginCtx)
 	go client.writeMessages(ginCtx)
+	go client.redeliverPending(ginCtx)
 }
 
-func checkOrigin(r *http.Request) bool {
+// checkOrigin accepts upgrades without an Origin header, which browsers
+// always send, and otherwise requires an allowed origin.
+func (m *Manager) checkOrigin(r *http.Request) bool {
 	origin := r.Header.Get("Origin")
-
-	switch origin {
-	case "http://localhost:8080":
+	if origin == "" || m.config.CORS.AllowsOrigin(origin) {
 		return true
-	default:
-		return false
 	}
+
+	observability.RecordRejectedOrigin("websocket", origin)
+	return false
 }
@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/hiumesh/go-chat-server/internal/conf"
+	"github.com/hiumesh/go-chat-server/internal/memory_storage"
+	"github.com/hiumesh/go-chat-server/internal/models"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+const testSecret = "test-secret"
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	config := &conf.GlobalConfiguration{}
+	config.SERVER = conf.ServerConfiguration{
+		Id:                   "test",
+		MaxPerUserConnection: "2",
+		ReplayBufferSize:     100,
+		ReplayBufferTTL:      time.Hour,
+		PendingDeliveryTTL:   time.Hour,
+		TokenExpiryWarning:   time.Minute,
+		RevocationTTL:        time.Hour,
+		EgressQueueSize:      4,
+		EgressOverflow:       EgressOverflowDropOldest,
+		BatchMaxSize:         32,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	parser := utils.NewTokenParser(testSecret, nil, nil, "")
+	return NewManager(ctx, config, rdb, memory_storage.NewStore(), NewMemoryRegistry(), NewMemoryBus(), parser)
+}
+
+// newTestClient adds a client without connection to the manager, like the
+// virtual clients of /sse and /poll.
+func newTestClient(t *testing.T, m *Manager, claims *utils.AccessTokenClaims) *Client {
+	t.Helper()
+
+	client := &Client{
+		connectionId: uuid.NewString(),
+		manager:      m,
+		egress:       make(chan Event, m.config.SERVER.EgressQueueSize),
+		typing:       make(map[string]*typingState),
+		threads:      make(map[string]bool),
+		codec:        jsonCodec{},
+		done:         make(chan struct{}),
+		heldEvents:   make(map[int64]Event),
+		gaps:         make(chan struct{}, 1),
+	}
+	client.accessClaims.Store(claims)
+	m.addClient(client)
+	t.Cleanup(func() { m.removeClient(client) })
+	return client
+}
+
+// registerTestClient adds the client to the registry, so events delivered to
+// its user reach it.
+func registerTestClient(t *testing.T, c *Client) {
+	t.Helper()
+
+	if err := c.manager.registry.Register(context.Background(), c.claims().Subject, c.registryEntry(), 2, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testClaims(subject string, expiresIn time.Duration) *utils.AccessTokenClaims {
+	now := time.Now()
+	return &utils.AccessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		},
+	}
+}
+
+func signTestToken(t *testing.T, claims *utils.AccessTokenClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func testEvent(text string) Event {
+	data, _ := json.Marshal(text)
+	return Event{Type: EventNewMessage, Payload: data}
+}
+
+func directMessageEvent(t *testing.T, message SendDirectMessageEvent) Event {
+	t.Helper()
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Event{Type: EventSendDirectMessage, Payload: data}
+}
+
+// nextEvent returns the next event queued for the client.
+func nextEvent(t *testing.T, c *Client, timeout time.Duration) Event {
+	t.Helper()
+
+	select {
+	case event := <-c.egress:
+		return event
+	case <-time.After(timeout):
+		t.Fatal("no event queued")
+		return Event{}
+	}
+}
+
+// nextEventOfType skips the queued events until one of the given type.
+func nextEventOfType(t *testing.T, c *Client, eventType string) Event {
+	t.Helper()
+
+	for {
+		if event := nextEvent(t, c, time.Second); event.Type == eventType {
+			return event
+		}
+	}
+}
+
+// recordTestMessage stores the message as sent to the participants.
+func recordTestMessage(t *testing.T, m *Manager, message *models.Message, participantIds ...string) {
+	t.Helper()
+
+	if err := m.store.InsertMessage(message); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.store.RecordConversationMessage(message, participantIds); err != nil {
+		t.Fatal(err)
+	}
+}
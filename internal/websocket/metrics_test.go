@@ -0,0 +1,57 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/observability"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRouteEventCountsEvents(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+	ctx := context.Background()
+
+	handled := testutil.ToFloat64(observability.EventsHandled.WithLabelValues(EventSendDirectMessage))
+	failed := testutil.ToFloat64(observability.EventErrors.WithLabelValues(EventSendDirectMessage))
+	unsupported := testutil.ToFloat64(observability.EventErrors.WithLabelValues("unsupported"))
+
+	if err := m.routeEvent(ctx, directMessageEvent(t, SendDirectMessageEvent{ClientMessageId: "1", To: "bob", Body: "hello"}), c); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.routeEvent(ctx, Event{Type: EventSendDirectMessage, Payload: json.RawMessage(`[]`)}, c); err == nil {
+		t.Fatal("malformed event accepted")
+	}
+	if err := m.routeEvent(ctx, Event{Type: "unknown"}, c); err != ErrEventNotSupported {
+		t.Fatalf("got %v, want %v", err, ErrEventNotSupported)
+	}
+
+	if got := testutil.ToFloat64(observability.EventsHandled.WithLabelValues(EventSendDirectMessage)) - handled; got != 2 {
+		t.Errorf("got %v events handled, want 2", got)
+	}
+	if got := testutil.ToFloat64(observability.EventErrors.WithLabelValues(EventSendDirectMessage)) - failed; got != 1 {
+		t.Errorf("got %v event errors, want 1", got)
+	}
+	if got := testutil.ToFloat64(observability.EventErrors.WithLabelValues("unsupported")) - unsupported; got != 1 {
+		t.Errorf("got %v unsupported events, want 1", got)
+	}
+}
+
+func TestConnectedClientsFollowsConnections(t *testing.T) {
+	m := newTestManager(t)
+	connected := testutil.ToFloat64(observability.ConnectedClients)
+
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+	if got := testutil.ToFloat64(observability.ConnectedClients) - connected; got != 1 {
+		t.Errorf("got %v connected clients, want 1", got)
+	}
+
+	m.removeClient(c)
+	m.removeClient(c)
+	if got := testutil.ToFloat64(observability.ConnectedClients) - connected; got != 0 {
+		t.Errorf("got %v connected clients after the close, want 0", got)
+	}
+}
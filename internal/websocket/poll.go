@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// ServePoll answers a long poll with the events queued for the virtual
+// client, waiting up to the poll timeout for the first one. A poll without
+// connection_id connects a new client, its first event is connected.
+func (m *Manager) ServePoll(ginCtx *gin.Context) {
+	claims := utils.GetClaims(ginCtx)
+	if claims == nil {
+		utils.HandleHttpError(utils.UnauthorizedError("claims not found"), ginCtx)
+		return
+	}
+
+	connectionId := ginCtx.Query("connection_id")
+	if connectionId == "" {
+		client, ctx, err := m.connectVirtual(ginCtx)
+		if err != nil {
+			logrus.Errorf("failed to setup the connection: %+v", err)
+			utils.HandleHttpError(utils.InternalServerError("Failed to setup the connection: %+v", err), ginCtx)
+			return
+		}
+		client.polledAt.Store(time.Now().UnixNano())
+		go client.watchPolls(ctx)
+		connectionId = client.connectionId
+	}
+
+	m.RLock()
+	client, ok := m.clients[connectionId]
+	m.RUnlock()
+	if !ok || client.claims().Subject != claims.Subject {
+		utils.HandleHttpError(utils.NotFoundError("%v", ErrConnectionNotFound), ginCtx)
+		return
+	}
+
+	if !client.polling.CompareAndSwap(false, true) {
+		utils.HandleHttpError(utils.ConflictError("connection is already polled"), ginCtx)
+		return
+	}
+	defer func() {
+		client.polledAt.Store(time.Now().UnixNano())
+		client.polling.Store(false)
+	}()
+
+	timer := time.NewTimer(m.config.SERVER.PollTimeout)
+	defer timer.Stop()
+
+	// A failed response is answered again before the events queued since.
+	events := client.unsent
+	client.unsent = nil
+	if len(events) > 0 {
+		writePoll(ginCtx, client, client.drainEgress(events))
+		return
+	}
+
+	events = []Event{}
+	select {
+	case event := <-client.egress:
+		events = client.drainEgress(append(events, event))
+	case <-client.done:
+		events = append(events, client.closeEvent())
+	case <-timer.C:
+	case <-ginCtx.Request.Context().Done():
+		return
+	}
+
+	writePoll(ginCtx, client, events)
+}
+
+// writePoll answers the poll with the events, keeping them for the next poll
+// when the response cannot be written.
+func writePoll(ginCtx *gin.Context, client *Client, events []Event) {
+	data, err := json.Marshal(events)
+	if err != nil {
+		logrus.Errorf("error marshaling the poll response: %v", err)
+		utils.HandleHttpError(utils.InternalServerError("Failed to encode the events"), ginCtx)
+		return
+	}
+
+	ginCtx.Header("Content-Type", "application/json; charset=utf-8")
+	ginCtx.Status(http.StatusOK)
+	_, err = ginCtx.Writer.Write(data)
+	ginCtx.Writer.Flush()
+	if err == nil {
+		// the request context ends once the client hung up
+		err = ginCtx.Request.Context().Err()
+	}
+	if err != nil {
+		logrus.Errorf("error writing the poll response: %v", err)
+		client.unsent = events
+	}
+}
+
+// watchPolls disconnects the virtual client once it stops polling for longer
+// than the idle timeout and keeps it alive in the registry meanwhile.
+func (c *Client) watchPolls(ctx context.Context) {
+	defer c.disconnectVirtual(ctx)
+
+	ticker := time.NewTicker(pingInterval)
+	redisPingTicker := time.NewTicker(time.Duration(redisPingInterval))
+	defer func() {
+		ticker.Stop()
+		redisPingTicker.Stop()
+	}()
+
+	for {
+		select {
+		case <-ticker.C:
+			idle := time.Since(time.Unix(0, c.polledAt.Load()))
+			if !c.polling.Load() && idle > c.manager.config.SERVER.PollIdleTimeout {
+				c.closeWithCode(CloseNormal, "poll timeout")
+			}
+		case <-redisPingTicker.C:
+			if err := c.manager.registry.Heartbeat(ctx, c.claims().Subject, c.registryEntry()); err != nil {
+				logrus.Errorf("redis ping fail: %v", err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
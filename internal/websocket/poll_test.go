@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+)
+
+func newPollContext(t *testing.T, ctx context.Context, c *Client) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ginCtx.Request = httptest.NewRequest(http.MethodGet, "/poll?connection_id="+c.connectionId, nil).WithContext(ctx)
+	utils.WithToken(ginCtx, &jwt.Token{Claims: c.claims()})
+	return ginCtx, recorder
+}
+
+func TestPollAnswersAgainAfterFailedWrite(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+	c.enqueue(testEvent("hello"))
+
+	// The client hung up before the response was written.
+	hungUp, cancel := context.WithCancel(context.Background())
+	cancel()
+	ginCtx, _ := newPollContext(t, hungUp, c)
+	writePoll(ginCtx, c, c.drainEgress(nil))
+
+	ginCtx, recorder := newPollContext(t, context.Background(), c)
+	m.ServePoll(ginCtx)
+
+	var events []Event
+	if err := json.Unmarshal(recorder.Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != EventNewMessage {
+		t.Errorf("got %+v, want the unsent event", events)
+	}
+}
@@ -0,0 +1,290 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+var presenceAwayAfter = 2 * time.Minute
+var presenceStaleAfter = 5 * time.Minute
+var presenceSubscriptionTTL = 24 * time.Hour
+
+const maxPresenceSubscriptions = 200
+
+// ErrPresenceNotVisible is returned when subscribing to the presence of a user
+// who shares no conversation with the caller.
+var ErrPresenceNotVisible = errors.New("user shares no conversation with the subscriber")
+
+const PresenceOnline = "online"
+const PresenceAway = "away"
+const PresenceOffline = "offline"
+
+const EventSubscribePresence = "subscribe_presence"
+const EventUnsubscribePresence = "unsubscribe_presence"
+const EventSetPresence = "set_presence"
+const EventPresence = "presence"
+const EventPresenceChanged = "presence_changed"
+
+type PresenceSubscriptionEvent struct {
+	UserIds []string `json:"user_ids"`
+}
+
+type SetPresenceEvent struct {
+	Status string `json:"status"`
+}
+
+type Presence struct {
+	UserId     string     `json:"user_id"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+type PresenceEvent struct {
+	Users []Presence `json:"users"`
+}
+
+func presenceStatusKey(userId string) string {
+	return "presence_status:" + userId
+}
+
+func presenceSubscribersKey(userId string) string {
+	return "presence_subscribers:" + userId
+}
+
+// GetPresence derives the status of the user from the heartbeat scores of
+// their entries in the connection registry, falling back to the last-seen
+// timestamp stored in Scylla once every connection is gone.
+func (m *Manager) GetPresence(ctx context.Context, userId string) (Presence, error) {
+	presence := Presence{UserId: userId, Status: PresenceOffline}
+	now := time.Now()
+
+	connections, err := m.registry.Connections(ctx, userId, now.Add(-presenceStaleAfter))
+	if err != nil {
+		return presence, err
+	}
+
+	if len(connections) == 0 {
+		lastSeenAt, err := m.store.GetLastSeen(userId)
+		if err != nil {
+			return presence, err
+		}
+		if !lastSeenAt.IsZero() {
+			presence.LastSeenAt = &lastSeenAt
+		}
+		return presence, nil
+	}
+
+	lastSeenAt := connections[0].HeartbeatAt
+	for _, connection := range connections {
+		if connection.HeartbeatAt.After(lastSeenAt) {
+			lastSeenAt = connection.HeartbeatAt
+		}
+	}
+	presence.LastSeenAt = &lastSeenAt
+	presence.Status = PresenceOnline
+
+	status, err := m.rdb.Get(ctx, presenceStatusKey(userId)).Result()
+	if err != nil && err != redis.Nil {
+		return presence, err
+	}
+	if status == PresenceAway || now.Sub(lastSeenAt) > presenceAwayAfter {
+		presence.Status = PresenceAway
+	}
+
+	return presence, nil
+}
+
+// broadcastPresence pushes the current presence of the user to every user
+// subscribed to it. Presence events are not persisted nor sequenced.
+func (m *Manager) broadcastPresence(ctx context.Context, userId string) error {
+	subscriberIds, err := m.rdb.SMembers(ctx, presenceSubscribersKey(userId)).Result()
+	if err != nil || len(subscriberIds) == 0 {
+		return err
+	}
+
+	presence, err := m.GetPresence(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(presence)
+	if err != nil {
+		return err
+	}
+
+	outgoingEvent := Event{Type: EventPresenceChanged, Payload: data}
+	for _, subscriberId := range subscriberIds {
+		if err := m.deliverToUser(ctx, subscriberId, outgoingEvent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userConnected announces the user as online when the connection is the
+// first one in the registry.
+func (m *Manager) userConnected(ctx context.Context, userId string) {
+	connections, err := m.registry.Connections(ctx, userId, time.Time{})
+	if err != nil {
+		logrus.Errorf("error counting user connections: %v", err)
+		return
+	}
+
+	if len(connections) == 1 {
+		if err := m.broadcastPresence(ctx, userId); err != nil {
+			logrus.Errorf("error broadcasting presence: %v", err)
+		}
+	}
+}
+
+// userDisconnected records the last-seen timestamp and announces the user as
+// offline once their final connection is gone.
+func (m *Manager) userDisconnected(ctx context.Context, userId string) {
+	connections, err := m.registry.Connections(ctx, userId, time.Now().Add(-presenceStaleAfter))
+	if err != nil {
+		logrus.Errorf("error counting user connections: %v", err)
+		return
+	}
+	if len(connections) > 0 {
+		return
+	}
+
+	if err := m.store.UpdateLastSeen(userId, time.Now()); err != nil {
+		logrus.Errorf("error updating last seen: %v", err)
+	}
+
+	if err := m.rdb.Del(ctx, presenceStatusKey(userId)).Err(); err != nil {
+		logrus.Errorf("error clearing presence status: %v", err)
+	}
+
+	if err := m.broadcastPresence(ctx, userId); err != nil {
+		logrus.Errorf("error broadcasting presence: %v", err)
+	}
+}
+
+// visibleUsers returns the users whose presence the user may follow, the
+// peers of their direct conversations and the members of their channels.
+func (m *Manager) visibleUsers(userId string) (map[string]bool, error) {
+	conversations, err := m.store.ListUserConversations(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := map[string]bool{userId: true}
+	for _, conversation := range conversations {
+		if conversation.PeerId != "" {
+			visible[conversation.PeerId] = true
+			continue
+		}
+		if conversation.ChannelId == "" {
+			continue
+		}
+
+		memberIds, err := m.store.GetChannelUserIds(conversation.ChannelId)
+		if err != nil {
+			return nil, err
+		}
+		// the user may have left the channel since
+		if !slices.Contains(memberIds, userId) {
+			continue
+		}
+		for _, memberId := range memberIds {
+			visible[memberId] = true
+		}
+	}
+	return visible, nil
+}
+
+func SubscribePresenceHandler(ctx context.Context, event Event, c *Client) error {
+	manager := c.manager
+	var subscription PresenceSubscriptionEvent
+	if err := json.Unmarshal(event.Payload, &subscription); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	if len(subscription.UserIds) > maxPresenceSubscriptions {
+		return fmt.Errorf("at most %d users can be subscribed at once", maxPresenceSubscriptions)
+	}
+
+	visible, err := manager.visibleUsers(c.claims().Subject)
+	if err != nil {
+		return err
+	}
+	for _, userId := range subscription.UserIds {
+		if !visible[userId] {
+			return fmt.Errorf("%w: %s", ErrPresenceNotVisible, userId)
+		}
+	}
+
+	snapshot := PresenceEvent{Users: make([]Presence, 0, len(subscription.UserIds))}
+	for _, userId := range subscription.UserIds {
+		key := presenceSubscribersKey(userId)
+		_, err := manager.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SAdd(ctx, key, c.claims().Subject)
+			pipe.Expire(ctx, key, presenceSubscriptionTTL)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		presence, err := manager.GetPresence(ctx, userId)
+		if err != nil {
+			return err
+		}
+		snapshot.Users = append(snapshot.Users, presence)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	c.enqueue(Event{Type: EventPresence, Payload: data})
+	return nil
+}
+
+func UnsubscribePresenceHandler(ctx context.Context, event Event, c *Client) error {
+	var subscription PresenceSubscriptionEvent
+	if err := json.Unmarshal(event.Payload, &subscription); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	for _, userId := range subscription.UserIds {
+		if err := c.manager.rdb.SRem(ctx, presenceSubscribersKey(userId), c.claims().Subject).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func SetPresenceHandler(ctx context.Context, event Event, c *Client) error {
+	manager := c.manager
+	var setPresenceEvent SetPresenceEvent
+	if err := json.Unmarshal(event.Payload, &setPresenceEvent); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	key := presenceStatusKey(c.claims().Subject)
+	switch setPresenceEvent.Status {
+	case PresenceAway:
+		if err := manager.rdb.Set(ctx, key, PresenceAway, presenceStaleAfter).Err(); err != nil {
+			return err
+		}
+	case PresenceOnline:
+		if err := manager.rdb.Del(ctx, key).Err(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported presence status: %q", setPresenceEvent.Status)
+	}
+
+	return manager.broadcastPresence(ctx, c.claims().Subject)
+}
@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/memory_storage"
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+func subscribePresenceEvent(t *testing.T, userIds ...string) Event {
+	t.Helper()
+
+	data, err := json.Marshal(PresenceSubscriptionEvent{UserIds: userIds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Event{Type: EventSubscribePresence, Payload: data}
+}
+
+func TestSubscribePresenceRequiresSharedConversation(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+	ctx := context.Background()
+
+	err := SubscribePresenceHandler(ctx, subscribePresenceEvent(t, "bob"), c)
+	if !errors.Is(err, ErrPresenceNotVisible) {
+		t.Fatalf("got %v, want %v", err, ErrPresenceNotVisible)
+	}
+
+	recordTestMessage(t, m, &models.Message{
+		ConversationId: models.DirectConversationId("alice", "bob"),
+		UserId:         "bob",
+		Body:           "hello",
+	}, "alice", "bob")
+
+	if err := SubscribePresenceHandler(ctx, subscribePresenceEvent(t, "bob"), c); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, c, time.Second); event.Type != EventPresence {
+		t.Errorf("got %q, want %q", event.Type, EventPresence)
+	}
+}
+
+func TestSubscribePresenceAllowsChannelMembers(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+	ctx := context.Background()
+
+	store := m.store.(*memory_storage.Store)
+	for _, userId := range []string{"alice", "bob"} {
+		store.AddChannelUser("general", userId)
+	}
+	recordTestMessage(t, m, &models.Message{
+		ConversationId: "general",
+		ChannelId:      "general",
+		UserId:         "bob",
+		Body:           "hello",
+	}, "alice", "bob")
+
+	if err := SubscribePresenceHandler(ctx, subscribePresenceEvent(t, "bob"), c); err != nil {
+		t.Fatal(err)
+	}
+
+	// carol is not a member of the channel
+	err := SubscribePresenceHandler(ctx, subscribePresenceEvent(t, "bob", "carol"), c)
+	if !errors.Is(err, ErrPresenceNotVisible) {
+		t.Fatalf("got %v, want %v", err, ErrPresenceNotVisible)
+	}
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+const EventAddReaction = "add_reaction"
+const EventRemoveReaction = "remove_reaction"
+const EventReactionAdded = "reaction_added"
+const EventReactionRemoved = "reaction_removed"
+
+const maxEmojiLength = 64
+
+var ErrInvalidEmoji = errors.New("emoji is required and must be at most 64 bytes")
+
+type ReactionRequestEvent struct {
+	MessageId string `json:"message_id"`
+	Emoji     string `json:"emoji"`
+}
+
+type ReactionChangedEvent struct {
+	MessageId      string `json:"message_id"`
+	ConversationId string `json:"conversation_id"`
+	ChannelId      string `json:"channel_id,omitempty"`
+	Emoji          string `json:"emoji"`
+	UserId         string `json:"user_id"`
+	Count          int    `json:"count"`
+}
+
+func AddReactionHandler(ctx context.Context, event Event, c *Client) error {
+	return changeReaction(ctx, event, c, EventReactionAdded)
+}
+
+func RemoveReactionHandler(ctx context.Context, event Event, c *Client) error {
+	return changeReaction(ctx, event, c, EventReactionRemoved)
+}
+
+func changeReaction(ctx context.Context, event Event, c *Client, eventType string) error {
+	manager := c.manager
+	var request ReactionRequestEvent
+	if err := json.Unmarshal(event.Payload, &request); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	if request.Emoji == "" || len(request.Emoji) > maxEmojiLength {
+		return ErrInvalidEmoji
+	}
+
+	message, err := manager.store.GetMessage(request.MessageId)
+	if err != nil {
+		return err
+	}
+	if message.DeletedAt != nil {
+		return ErrMessageDeleted
+	}
+
+	participantIds, err := manager.messageParticipants(c.claims(), message)
+	if err != nil {
+		return err
+	}
+
+	if eventType == EventReactionAdded {
+		err = manager.store.AddReaction(message, request.Emoji, c.claims().Subject)
+	} else {
+		err = manager.store.RemoveReaction(message, request.Emoji, c.claims().Subject)
+	}
+	if err != nil {
+		return err
+	}
+
+	count, err := manager.store.CountReaction(message, request.Emoji)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(ReactionChangedEvent{
+		MessageId:      message.Id,
+		ConversationId: message.ConversationId,
+		ChannelId:      message.ChannelId,
+		Emoji:          request.Emoji,
+		UserId:         c.claims().Subject,
+		Count:          count,
+	})
+	if err != nil {
+		return err
+	}
+
+	return manager.deliverToUsers(ctx, participantIds, Event{Type: eventType, Payload: data})
+}
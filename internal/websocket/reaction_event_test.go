@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+func reactionEvent(t *testing.T, eventType string, messageId string, emoji string) Event {
+	t.Helper()
+
+	data, err := json.Marshal(ReactionRequestEvent{MessageId: messageId, Emoji: emoji})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Event{Type: eventType, Payload: data}
+}
+
+func nextReaction(t *testing.T, c *Client, eventType string) ReactionChangedEvent {
+	t.Helper()
+
+	var reaction ReactionChangedEvent
+	if err := json.Unmarshal(nextEventOfType(t, c, eventType).Payload, &reaction); err != nil {
+		t.Fatal(err)
+	}
+	return reaction
+}
+
+func TestReactionsToggleOncePerUser(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	registerTestClient(t, alice)
+	ctx := context.Background()
+
+	message := &models.Message{ConversationId: models.DirectConversationId("alice", "bob"), UserId: "alice", Body: "hello"}
+	recordTestMessage(t, m, message, "alice", "bob")
+
+	// Adding the same reaction twice counts it once.
+	for _, want := range []int{1, 1} {
+		if err := AddReactionHandler(ctx, reactionEvent(t, EventAddReaction, message.Id, "👍"), bob); err != nil {
+			t.Fatal(err)
+		}
+		if reaction := nextReaction(t, alice, EventReactionAdded); reaction.Count != want || reaction.UserId != "bob" {
+			t.Errorf("got %+v, want bob's reaction counted %d", reaction, want)
+		}
+	}
+	if err := AddReactionHandler(ctx, reactionEvent(t, EventAddReaction, message.Id, "👍"), alice); err != nil {
+		t.Fatal(err)
+	}
+	if reaction := nextReaction(t, alice, EventReactionAdded); reaction.Count != 2 {
+		t.Errorf("got a count of %d, want 2", reaction.Count)
+	}
+
+	history, err := m.FetchHistory(alice.claims(), FetchHistoryEvent{UserId: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Messages) != 1 || len(history.Messages[0].Reactions) != 1 || history.Messages[0].Reactions[0].Count != 2 {
+		t.Errorf("got %+v in the history, want the reaction counted twice", history.Messages)
+	}
+
+	if err := RemoveReactionHandler(ctx, reactionEvent(t, EventRemoveReaction, message.Id, "👍"), bob); err != nil {
+		t.Fatal(err)
+	}
+	if reaction := nextReaction(t, alice, EventReactionRemoved); reaction.Count != 1 {
+		t.Errorf("got a count of %d after removing, want 1", reaction.Count)
+	}
+}
+
+func TestReactionsAreValidated(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	carol := newTestClient(t, m, testClaims("carol", time.Hour))
+	ctx := context.Background()
+
+	message := &models.Message{ConversationId: models.DirectConversationId("alice", "bob"), UserId: "alice", Body: "hello"}
+	recordTestMessage(t, m, message, "alice", "bob")
+
+	for _, emoji := range []string{"", strings.Repeat("a", maxEmojiLength+1)} {
+		if err := AddReactionHandler(ctx, reactionEvent(t, EventAddReaction, message.Id, emoji), alice); !errors.Is(err, ErrInvalidEmoji) {
+			t.Errorf("got %v, want %v", err, ErrInvalidEmoji)
+		}
+	}
+	if err := AddReactionHandler(ctx, reactionEvent(t, EventAddReaction, message.Id, "👍"), carol); !errors.Is(err, ErrNotConversationParticipant) {
+		t.Errorf("got %v reacting as carol, want %v", err, ErrNotConversationParticipant)
+	}
+
+	if _, err := m.DeleteMessage(ctx, alice.claims(), message.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddReactionHandler(ctx, reactionEvent(t, EventAddReaction, message.Id, "👍"), alice); !errors.Is(err, ErrMessageDeleted) {
+		t.Errorf("got %v reacting to a deleted message, want %v", err, ErrMessageDeleted)
+	}
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+)
+
+var ErrMessageNotInConversation = errors.New("message does not belong to the conversation")
+
+const EventMarkRead = "mark_read"
+const EventReadReceipt = "read_receipt"
+
+type MarkReadEvent struct {
+	ChannelId string `json:"channel_id,omitempty"`
+	UserId    string `json:"user_id,omitempty"`
+	MessageId string `json:"message_id"`
+}
+
+type ReadReceiptEvent struct {
+	ConversationId string    `json:"conversation_id"`
+	ChannelId      string    `json:"channel_id,omitempty"`
+	UserId         string    `json:"user_id"`
+	MessageId      string    `json:"message_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
+
+// conversationParticipants resolves the conversation like resolveConversation
+// and also returns the ids of every participant, the caller included.
+func (m *Manager) conversationParticipants(claims *utils.AccessTokenClaims, channelId string, userId string) (string, []string, error) {
+	switch {
+	case channelId != "":
+		memberIds, err := m.store.GetChannelUserIds(channelId)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, memberId := range memberIds {
+			if memberId == claims.Subject {
+				return channelId, memberIds, nil
+			}
+		}
+		return "", nil, ErrNotChannelMember
+	case userId != "":
+		return models.DirectConversationId(claims.Subject, userId), []string{claims.Subject, userId}, nil
+	default:
+		return "", nil, ErrConversationRequired
+	}
+}
+
+func MarkReadHandler(ctx context.Context, event Event, c *Client) error {
+	manager := c.manager
+	var markReadEvent MarkReadEvent
+	if err := json.Unmarshal(event.Payload, &markReadEvent); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	conversationId, participantIds, err := manager.conversationParticipants(c.claims(), markReadEvent.ChannelId, markReadEvent.UserId)
+	if err != nil {
+		return err
+	}
+
+	message, err := manager.store.GetMessage(markReadEvent.MessageId)
+	if err != nil {
+		return err
+	}
+	if message.ConversationId != conversationId {
+		return ErrMessageNotInConversation
+	}
+
+	moved, err := manager.store.MarkConversationRead(c.claims().Subject, conversationId, markReadEvent.MessageId)
+	if err != nil || !moved {
+		return err
+	}
+
+	data, err := json.Marshal(ReadReceiptEvent{
+		ConversationId: conversationId,
+		ChannelId:      markReadEvent.ChannelId,
+		UserId:         c.claims().Subject,
+		MessageId:      markReadEvent.MessageId,
+		ReadAt:         time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return manager.deliverToUsers(ctx, participantIds, Event{Type: EventReadReceipt, Payload: data})
+}
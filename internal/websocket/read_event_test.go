@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+func markReadEvent(t *testing.T, request MarkReadEvent) Event {
+	t.Helper()
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Event{Type: EventMarkRead, Payload: data}
+}
+
+func unreadCount(t *testing.T, m *Manager, userId string, conversationId string) int64 {
+	t.Helper()
+
+	conversations, err := m.store.ListUserConversations(userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, conversation := range conversations {
+		if conversation.ConversationId == conversationId {
+			return conversation.Unread
+		}
+	}
+	t.Fatalf("conversation %v not listed", conversationId)
+	return 0
+}
+
+func TestMarkReadResetsUnreadCounter(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	registerTestClient(t, alice)
+	ctx := context.Background()
+
+	conversationId := models.DirectConversationId("alice", "bob")
+	var messages []*models.Message
+	for _, body := range []string{"first", "second", "third"} {
+		message := &models.Message{ConversationId: conversationId, UserId: "alice", Body: body}
+		recordTestMessage(t, m, message, "alice", "bob")
+		messages = append(messages, message)
+	}
+	if unread := unreadCount(t, m, "bob", conversationId); unread != 3 {
+		t.Fatalf("got %d unread, want 3", unread)
+	}
+
+	if err := MarkReadHandler(ctx, markReadEvent(t, MarkReadEvent{UserId: "alice", MessageId: messages[1].Id}), bob); err != nil {
+		t.Fatal(err)
+	}
+	if unread := unreadCount(t, m, "bob", conversationId); unread != 1 {
+		t.Errorf("got %d unread, want 1", unread)
+	}
+
+	var receipt ReadReceiptEvent
+	if err := json.Unmarshal(nextEventOfType(t, alice, EventReadReceipt).Payload, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.UserId != "bob" || receipt.MessageId != messages[1].Id {
+		t.Errorf("got %+v, want bob reading %v", receipt, messages[1].Id)
+	}
+
+	// Marking an earlier message does not move the position back.
+	if err := MarkReadHandler(ctx, markReadEvent(t, MarkReadEvent{UserId: "alice", MessageId: messages[0].Id}), bob); err != nil {
+		t.Fatal(err)
+	}
+	if unread := unreadCount(t, m, "bob", conversationId); unread != 1 {
+		t.Errorf("got %d unread after marking an earlier message, want 1", unread)
+	}
+	select {
+	case event := <-alice.egress:
+		t.Errorf("got %q for an earlier message", event.Type)
+	default:
+	}
+
+	if err := MarkReadHandler(ctx, markReadEvent(t, MarkReadEvent{UserId: "alice", MessageId: messages[2].Id}), bob); err != nil {
+		t.Fatal(err)
+	}
+	if unread := unreadCount(t, m, "bob", conversationId); unread != 0 {
+		t.Errorf("got %d unread, want 0", unread)
+	}
+}
+
+func TestMarkReadRejectsMessagesOfOtherConversations(t *testing.T) {
+	m := newTestManager(t)
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	ctx := context.Background()
+
+	conversationId := models.DirectConversationId("alice", "bob")
+	recordTestMessage(t, m, &models.Message{ConversationId: conversationId, UserId: "alice", Body: "hello"}, "alice", "bob")
+
+	// A later message of another conversation would mark everything read.
+	other := &models.Message{ConversationId: models.DirectConversationId("bob", "carol"), UserId: "carol", Body: "hello"}
+	recordTestMessage(t, m, other, "bob", "carol")
+
+	err := MarkReadHandler(ctx, markReadEvent(t, MarkReadEvent{UserId: "alice", MessageId: other.Id}), bob)
+	if !errors.Is(err, ErrMessageNotInConversation) {
+		t.Fatalf("got %v, want %v", err, ErrMessageNotInConversation)
+	}
+	if unread := unreadCount(t, m, "bob", conversationId); unread != 1 {
+		t.Errorf("got %d unread, want 1", unread)
+	}
+}
@@ -0,0 +1,29 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrConnectionLimitReached = errors.New("maximum connection limit reached")
+
+// RegisteredConnection is an entry of the connection registry. Entry has the
+// form "serverId connectionId".
+type RegisteredConnection struct {
+	Entry       string
+	HeartbeatAt time.Time
+}
+
+// ConnectionRegistry tracks which server holds the connections of a user.
+type ConnectionRegistry interface {
+	// Register adds the entry for the user, failing with
+	// ErrConnectionLimitReached once the user holds limit connections.
+	// Entries without a heartbeat for longer than staleAfter are dropped first.
+	Register(ctx context.Context, userId string, entry string, limit int, staleAfter time.Duration) error
+	Heartbeat(ctx context.Context, userId string, entry string) error
+	Unregister(ctx context.Context, userId string, entry string) error
+	// Connections lists the entries of the user with a heartbeat after since,
+	// a zero since lists all of them.
+	Connections(ctx context.Context, userId string, since time.Time) ([]RegisteredConnection, error)
+}
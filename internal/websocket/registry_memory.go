@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRegistry keeps the registry in process, for single-node deployments
+// and tests.
+type MemoryRegistry struct {
+	users map[string]map[string]time.Time
+	sync.Mutex
+}
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{users: make(map[string]map[string]time.Time)}
+}
+
+func (r *MemoryRegistry) Register(ctx context.Context, userId string, entry string, limit int, staleAfter time.Duration) error {
+	r.Lock()
+	defer r.Unlock()
+
+	connections, ok := r.users[userId]
+	if !ok {
+		connections = make(map[string]time.Time)
+		r.users[userId] = connections
+	}
+
+	staleBefore := time.Now().Add(-staleAfter)
+	for existing, heartbeatAt := range connections {
+		if !heartbeatAt.After(staleBefore) {
+			delete(connections, existing)
+		}
+	}
+
+	if len(connections) >= limit {
+		return ErrConnectionLimitReached
+	}
+
+	connections[entry] = time.Now()
+	return nil
+}
+
+func (r *MemoryRegistry) Heartbeat(ctx context.Context, userId string, entry string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.users[userId][entry]; ok {
+		r.users[userId][entry] = time.Now()
+	}
+	return nil
+}
+
+func (r *MemoryRegistry) Unregister(ctx context.Context, userId string, entry string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	delete(r.users[userId], entry)
+	if len(r.users[userId]) == 0 {
+		delete(r.users, userId)
+	}
+	return nil
+}
+
+func (r *MemoryRegistry) Connections(ctx context.Context, userId string, since time.Time) ([]RegisteredConnection, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	connections := make([]RegisteredConnection, 0, len(r.users[userId]))
+	for entry, heartbeatAt := range r.users[userId] {
+		if since.IsZero() || !heartbeatAt.Before(since) {
+			connections = append(connections, RegisteredConnection{Entry: entry, HeartbeatAt: heartbeatAt})
+		}
+	}
+
+	sort.Slice(connections, func(a, b int) bool {
+		return connections[a].HeartbeatAt.Before(connections[b].HeartbeatAt)
+	})
+	return connections, nil
+}
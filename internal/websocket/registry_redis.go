@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRegistry keeps the connections of each user in a sorted set keyed by
+// the user id, scored by the last heartbeat in milliseconds.
+type RedisRegistry struct {
+	rdb *redis.Client
+}
+
+func NewRedisRegistry(rdb *redis.Client) *RedisRegistry {
+	return &RedisRegistry{rdb: rdb}
+}
+
+func (r *RedisRegistry) Register(ctx context.Context, userId string, entry string, limit int, staleAfter time.Duration) error {
+	if err := r.rdb.ZRemRangeByScore(ctx, userId, "-inf", strconv.FormatInt(time.Now().Add(-staleAfter).UnixMilli(), 10)).Err(); err != nil {
+		return err
+	}
+
+	count, err := r.rdb.ZCard(ctx, userId).Result()
+	if err != nil {
+		return err
+	}
+	if count >= int64(limit) {
+		return ErrConnectionLimitReached
+	}
+
+	return r.rdb.ZAdd(ctx, userId, redis.Z{Score: float64(time.Now().UnixMilli()), Member: entry}).Err()
+}
+
+func (r *RedisRegistry) Heartbeat(ctx context.Context, userId string, entry string) error {
+	return r.rdb.ZAddXX(ctx, userId, redis.Z{Score: float64(time.Now().UnixMilli()), Member: entry}).Err()
+}
+
+func (r *RedisRegistry) Unregister(ctx context.Context, userId string, entry string) error {
+	return r.rdb.ZRem(ctx, userId, entry).Err()
+}
+
+func (r *RedisRegistry) Connections(ctx context.Context, userId string, since time.Time) ([]RegisteredConnection, error) {
+	min := "-inf"
+	if !since.IsZero() {
+		min = strconv.FormatInt(since.UnixMilli(), 10)
+	}
+
+	members, err := r.rdb.ZRangeByScoreWithScores(ctx, userId, &redis.ZRangeBy{Min: min, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	connections := make([]RegisteredConnection, 0, len(members))
+	for _, member := range members {
+		connections = append(connections, RegisteredConnection{
+			Entry:       member.Member,
+			HeartbeatAt: time.UnixMilli(int64(member.Score)),
+		})
+	}
+	return connections, nil
+}
@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testRegistries(t *testing.T) map[string]ConnectionRegistry {
+	t.Helper()
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	return map[string]ConnectionRegistry{
+		"memory": NewMemoryRegistry(),
+		"redis":  NewRedisRegistry(rdb),
+	}
+}
+
+func TestRegistryLimitsConnections(t *testing.T) {
+	for name, registry := range testRegistries(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			for _, entry := range []string{"server a", "server b"} {
+				if err := registry.Register(ctx, "alice", entry, 2, time.Minute); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := registry.Register(ctx, "alice", "server c", 2, time.Minute); !errors.Is(err, ErrConnectionLimitReached) {
+				t.Fatalf("got %v, want %v", err, ErrConnectionLimitReached)
+			}
+			// Other users have limits of their own.
+			if err := registry.Register(ctx, "bob", "server c", 2, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := registry.Unregister(ctx, "alice", "server a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.Register(ctx, "alice", "server c", 2, time.Minute); err != nil {
+				t.Fatalf("unregistering left no room: %v", err)
+			}
+
+			connections, err := registry.Connections(ctx, "alice", time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(connections) != 2 || connections[0].Entry != "server b" || connections[1].Entry != "server c" {
+				t.Errorf("got %+v, want server b then server c", connections)
+			}
+		})
+	}
+}
+
+func TestRegistryDropsStaleConnections(t *testing.T) {
+	for name, registry := range testRegistries(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			for _, entry := range []string{"server a", "server b"} {
+				if err := registry.Register(ctx, "alice", entry, 2, time.Minute); err != nil {
+					t.Fatal(err)
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			heartbeatAt := time.Now()
+			if err := registry.Heartbeat(ctx, "alice", "server b"); err != nil {
+				t.Fatal(err)
+			}
+
+			connections, err := registry.Connections(ctx, "alice", heartbeatAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(connections) != 1 || connections[0].Entry != "server b" {
+				t.Errorf("got %+v, want only the connection with a recent heartbeat", connections)
+			}
+
+			// server a missed its heartbeat and makes room for a new one.
+			if err := registry.Register(ctx, "alice", "server c", 2, 10*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			if connections, err = registry.Connections(ctx, "alice", time.Time{}); err != nil {
+				t.Fatal(err)
+			}
+			if len(connections) != 2 {
+				t.Errorf("got %+v, want server b and server c", connections)
+			}
+		})
+	}
+}
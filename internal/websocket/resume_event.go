@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const EventResume = "resume"
+const EventResumed = "resumed"
+const EventResyncRequired = "resync_required"
+
+type ResumeEvent struct {
+	LastSeq int64 `json:"last_seq"`
+}
+
+type ResumedEvent struct {
+	Seq      int64 `json:"seq"`
+	Replayed int   `json:"replayed"`
+}
+
+func sequenceKey(userId string) string {
+	return "seq:" + userId
+}
+
+func replayKey(userId string) string {
+	return "replay:" + userId
+}
+
+// ResumeHandler replays every buffered event the client missed since
+// last_seq. When the gap is no longer covered by the replay buffer the client
+// is told to resync through the history API instead.
+func ResumeHandler(ctx context.Context, event Event, c *Client) error {
+	var resumeEvent ResumeEvent
+	if err := json.Unmarshal(event.Payload, &resumeEvent); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	c.startResume()
+	return c.resume(ctx, resumeEvent.LastSeq)
+}
+
+// resume replays the buffered events sequenced after lastSeq, ending the
+// resume the caller started with startResume.
+func (c *Client) resume(ctx context.Context, lastSeq int64) error {
+	manager := c.manager
+	userId := c.claims().Subject
+
+	resumedSeq := int64(0)
+	defer func() {
+		c.finishResume(resumedSeq)
+	}()
+
+	currentSeq, err := manager.rdb.Get(ctx, sequenceKey(userId)).Int64()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	buffered, err := manager.replayedEvents(ctx, userId, lastSeq, 0)
+	if err != nil {
+		return err
+	}
+	resumedSeq = currentSeq
+
+	missed := currentSeq - lastSeq
+	if missed < 0 || int64(len(buffered)) < missed {
+		data, err := json.Marshal(ResumedEvent{Seq: currentSeq})
+		if err != nil {
+			return err
+		}
+		c.enqueue(Event{Type: EventResyncRequired, Payload: data})
+		return nil
+	}
+
+	replayed := 0
+	for _, bufferedEvent := range buffered {
+		if bufferedEvent.Seq > resumedSeq {
+			resumedSeq = bufferedEvent.Seq
+		}
+		if !c.wants(bufferedEvent) {
+			continue
+		}
+		// Replays may exceed the queue, wait for the writer instead of
+		// dropping. Only the reader of this connection is held up.
+		if !c.enqueueWait(ctx, bufferedEvent) {
+			return ErrConnectionNotFound
+		}
+		replayed++
+	}
+
+	data, err := json.Marshal(ResumedEvent{Seq: currentSeq, Replayed: replayed})
+	if err != nil {
+		return err
+	}
+	c.enqueue(Event{Type: EventResumed, Payload: data})
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequenceEventBuffersEventsInOrder(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.sequenceEvent(ctx, "alice", testEvent("hello")); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	buffered, err := m.replayedEvents(ctx, "alice", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buffered) != 20 {
+		t.Fatalf("got %d buffered events, want 20", len(buffered))
+	}
+	for i, event := range buffered {
+		if event.Seq != int64(i+1) {
+			t.Errorf("buffered event %d has seq %d", i, event.Seq)
+		}
+	}
+}
+
+func TestDeliverSequencedFillsGaps(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+	ctx := context.Background()
+
+	first, err := m.sequenceEvent(ctx, "alice", testEvent("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.sequenceEvent(ctx, "alice", testEvent("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A concurrent sender delivers the second event first.
+	m.deliverLocal(c.connectionId, second)
+	m.deliverLocal(c.connectionId, first)
+
+	for _, want := range []int64{1, 2} {
+		if event := nextEvent(t, c, time.Second); event.Seq != want {
+			t.Fatalf("got seq %d, want %d", event.Seq, want)
+		}
+	}
+	select {
+	case event := <-c.egress:
+		t.Errorf("event %d delivered twice", event.Seq)
+	default:
+	}
+}
+
+func TestResumeHoldsLiveEvents(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+	ctx := context.Background()
+
+	first, err := m.sequenceEvent(ctx, "alice", testEvent("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.startResume()
+	second, err := m.sequenceEvent(ctx, "alice", testEvent("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.deliverLocal(c.connectionId, second)
+	select {
+	case event := <-c.egress:
+		t.Fatalf("event %d delivered during resume", event.Seq)
+	default:
+	}
+
+	c.enqueue(first)
+	c.finishResume(first.Seq)
+
+	for _, want := range []int64{1, 2} {
+		if event := nextEvent(t, c, time.Second); event.Seq != want {
+			t.Fatalf("got seq %d, want %d", event.Seq, want)
+		}
+	}
+}
+
+func TestDeliverSequencedReadsMissedEvents(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+	ctx := context.Background()
+
+	// The first event never reaches the connection live.
+	if _, err := m.sequenceEvent(ctx, "alice", testEvent("first")); err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.sequenceEvent(ctx, "alice", testEvent("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.deliverLocal(c.connectionId, second)
+
+	for _, want := range []string{"first", "second"} {
+		if event := nextEvent(t, c, time.Second); string(event.Payload) != string(testEvent(want).Payload) {
+			t.Fatalf("got %s, want %q", event.Payload, want)
+		}
+	}
+}
+
+func TestDeliverSequencedSkipsTrimmedEvents(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Hour))
+	ctx := context.Background()
+
+	if _, err := m.sequenceEvent(ctx, "alice", testEvent("first")); err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.sequenceEvent(ctx, "alice", testEvent("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.rdb.Del(ctx, replayKey("alice")).Err(); err != nil {
+		t.Fatal(err)
+	}
+	m.deliverLocal(c.connectionId, second)
+
+	if event := nextEvent(t, c, time.Second); event.Seq != second.Seq {
+		t.Fatalf("got seq %d, want %d", event.Seq, second.Seq)
+	}
+}
@@ -0,0 +1,156 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// CloseSessionRevoked is sent to connections whose session has been revoked.
+const CloseSessionRevoked = 4003
+
+const SubscribeEventKill = "kill"
+
+type KillSubscribeEvent struct {
+	ConnectionIds []string `json:"connection_ids"`
+	SessionId     string   `json:"session_id,omitempty"`
+}
+
+func revokedSessionKey(sessionId string) string {
+	return "revoked_session:" + sessionId
+}
+
+// revokedUserKey holds the unix time of the revocation, tokens of the user
+// issued up to then are rejected.
+func revokedUserKey(userId string) string {
+	return "revoked_user:" + userId
+}
+
+func sessionUserKey(sessionId string) string {
+	return "session_user:" + sessionId
+}
+
+// Revoker records revoked sessions in Redis and asks the servers holding
+// their connections to close them. It only needs Redis and the bus so it can
+// be used outside of a running server.
+type Revoker struct {
+	rdb      *redis.Client
+	registry ConnectionRegistry
+	bus      Bus
+	ttl      time.Duration
+}
+
+func NewRevoker(rdb *redis.Client, registry ConnectionRegistry, bus Bus, ttl time.Duration) *Revoker {
+	return &Revoker{rdb: rdb, registry: registry, bus: bus, ttl: ttl}
+}
+
+func (r *Revoker) RevokeSession(ctx context.Context, sessionId string) error {
+	if err := r.rdb.Set(ctx, revokedSessionKey(sessionId), 1, r.ttl).Err(); err != nil {
+		return err
+	}
+
+	userId, err := r.rdb.Get(ctx, sessionUserKey(sessionId)).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.kill(ctx, userId, sessionId)
+}
+
+func (r *Revoker) RevokeUser(ctx context.Context, userId string) error {
+	if err := r.rdb.Set(ctx, revokedUserKey(userId), time.Now().Unix(), r.ttl).Err(); err != nil {
+		return err
+	}
+
+	return r.kill(ctx, userId, "")
+}
+
+// IsRevoked reports whether the session of the token or every session of its
+// user issued before the token has been revoked.
+func (r *Revoker) IsRevoked(ctx context.Context, claims *utils.AccessTokenClaims) (bool, error) {
+	keys := []string{revokedUserKey(claims.Subject)}
+	if claims.SessionId != "" {
+		keys = append(keys, revokedSessionKey(claims.SessionId))
+	}
+
+	values, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return false, err
+	}
+	if len(values) > 1 && values[1] != nil {
+		return true, nil
+	}
+	if values[0] == nil {
+		return false, nil
+	}
+
+	revokedAt, err := strconv.ParseInt(values[0].(string), 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return claims.IssuedAt == nil || claims.IssuedAt.Unix() <= revokedAt, nil
+}
+
+// trackSession remembers the user of a connected session so revoking the
+// session id alone can find its connections.
+func (r *Revoker) trackSession(ctx context.Context, claims *utils.AccessTokenClaims) error {
+	if claims.SessionId == "" {
+		return nil
+	}
+	return r.rdb.Set(ctx, sessionUserKey(claims.SessionId), claims.Subject, r.ttl).Err()
+}
+
+func (r *Revoker) kill(ctx context.Context, userId string, sessionId string) error {
+	connections, err := r.registry.Connections(ctx, userId, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	serverConnections := make(map[string][]string)
+	for _, connection := range connections {
+		serverId, connectionId, ok := strings.Cut(connection.Entry, " ")
+		if ok {
+			serverConnections[serverId] = append(serverConnections[serverId], connectionId)
+		}
+	}
+
+	for serverId, connectionIds := range serverConnections {
+		data, err := json.Marshal(KillSubscribeEvent{ConnectionIds: connectionIds, SessionId: sessionId})
+		if err != nil {
+			return err
+		}
+
+		if err := publish(ctx, r.bus, serverId, SubscribeEvent{Type: SubscribeEventKill, Payload: data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func SubscribeEventKillHandler(event SubscribeEvent, m *Manager) error {
+	var killEvent KillSubscribeEvent
+	if err := json.Unmarshal(event.Payload, &killEvent); err != nil {
+		return fmt.Errorf("bad payload in request: %v", err)
+	}
+
+	for _, connectionId := range killEvent.ConnectionIds {
+		m.RLock()
+		client, ok := m.clients[connectionId]
+		m.RUnlock()
+
+		if ok && (killEvent.SessionId == "" || client.claims().SessionId == killEvent.SessionId) {
+			// closing writes to the peer, keep the bus subscriber going
+			go client.closeWithCode(CloseSessionRevoked, "session revoked")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+)
+
+func sessionClaims(subject string, sessionId string) *utils.AccessTokenClaims {
+	claims := testClaims(subject, time.Hour)
+	claims.SessionId = sessionId
+	return claims
+}
+
+func assertRevoked(t *testing.T, r *Revoker, claims *utils.AccessTokenClaims, want bool) {
+	t.Helper()
+
+	revoked, err := r.IsRevoked(context.Background(), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != want {
+		t.Errorf("got revoked %v for session %q issued at %v, want %v", revoked, claims.SessionId, claims.IssuedAt, want)
+	}
+}
+
+func TestRevokeSessionRejectsOnlyItsTokens(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	if err := m.revoker.RevokeSession(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked(t, m.revoker, sessionClaims("alice", "s1"), true)
+	assertRevoked(t, m.revoker, sessionClaims("alice", "s2"), false)
+	assertRevoked(t, m.revoker, sessionClaims("alice", ""), false)
+}
+
+func TestRevokeUserRejectsTokensIssuedBefore(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	if err := m.revoker.RevokeUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked(t, m.revoker, sessionClaims("alice", "s1"), true)
+	assertRevoked(t, m.revoker, sessionClaims("bob", "s1"), false)
+
+	// A token issued after the revocation, by a later login, is accepted.
+	issuedLater := sessionClaims("alice", "s2")
+	issuedLater.IssuedAt = jwt.NewNumericDate(time.Now().Add(2 * time.Second))
+	assertRevoked(t, m.revoker, issuedLater, false)
+}
+
+func TestRevokeSessionClosesItsConnections(t *testing.T) {
+	m := newTestManager(t)
+	revoked := newTestClient(t, m, sessionClaims("alice", "s1"))
+	kept := newTestClient(t, m, sessionClaims("alice", "s2"))
+	ctx := context.Background()
+
+	for _, c := range []*Client{revoked, kept} {
+		registerTestClient(t, c)
+		if err := m.revoker.trackSession(ctx, c.claims()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.revoker.RevokeSession(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-revoked.done:
+	case <-time.After(time.Second):
+		t.Fatal("connection of the revoked session not closed")
+	}
+	if revoked.closeCode != CloseSessionRevoked {
+		t.Errorf("got close code %d, want %d", revoked.closeCode, CloseSessionRevoked)
+	}
+	select {
+	case <-kept.done:
+		t.Error("connection of another session closed")
+	default:
+	}
+}
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	_ "embed"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+// EventProto is the protobuf definition of the envelopes used by the proto
+// codec.
+//
+//go:embed event.proto
+var EventProto []byte
+
+// EventPayloads maps every event exchanged with clients to its payload. Events
+// sent both ways are mapped to the payload the server sends.
+var EventPayloads = map[string]interface{}{
+	EventBatch:               []Event{},
+	EventSendDirectMessage:   SendDirectMessageEvent{},
+	EventNewMessage:          NewMessageEvent{},
+	EventChangeRoom:          ChangeRoomEvent{},
+	EventSendChannelMessage:  SendChannelMessageEvent{},
+	EventNewChannelMessage:   NewChannelMessageEvent{},
+	EventMessageAck:          MessageAckEvent{},
+	EventDelivered:           DeliveredEvent{},
+	EventMessageDelivered:    MessageDeliveredEvent{},
+	EventEditMessage:         EditMessageEvent{},
+	EventDeleteMessage:       DeleteMessageEvent{},
+	EventMessageUpdated:      models.Message{},
+	EventMessageDeleted:      MessageDeletedEvent{},
+	EventFetchHistory:        FetchHistoryEvent{},
+	EventHistory:             HistoryEvent{},
+	EventSubscribePresence:   PresenceSubscriptionEvent{},
+	EventUnsubscribePresence: PresenceSubscriptionEvent{},
+	EventSetPresence:         SetPresenceEvent{},
+	EventPresence:            PresenceEvent{},
+	EventPresenceChanged:     Presence{},
+	EventAddReaction:         ReactionRequestEvent{},
+	EventRemoveReaction:      ReactionRequestEvent{},
+	EventReactionAdded:       ReactionChangedEvent{},
+	EventReactionRemoved:     ReactionChangedEvent{},
+	EventMarkRead:            MarkReadEvent{},
+	EventReadReceipt:         ReadReceiptEvent{},
+	EventResume:              ResumeEvent{},
+	EventResumed:             ResumedEvent{},
+	EventResyncRequired:      ResumedEvent{},
+	EventOpenThread:          ThreadRequestEvent{},
+	EventCloseThread:         ThreadRequestEvent{},
+	EventFetchThread:         FetchThreadEvent{},
+	EventThread:              ThreadEvent{},
+	EventNewThreadMessage:    models.Message{},
+	EventThreadUpdated:       ThreadUpdatedEvent{},
+	EventTokenExpiring:       TokenExpiryEvent{},
+	EventRefreshToken:        RefreshTokenEvent{},
+	EventTokenRefreshed:      TokenExpiryEvent{},
+	EventTypingStart:         TypingEvent{},
+	EventTypingStop:          TypingEvent{},
+	EventConnected:           ConnectedEvent{},
+	EventClose:               CloseEvent{},
+}
+
+// SubscribeEventPayloads maps every event exchanged between nodes to its
+// payload.
+var SubscribeEventPayloads = map[string]interface{}{
+	SubscribeEventDeliver: DeliverSubscribeEvent{},
+	SubscribeEventKill:    KillSubscribeEvent{},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// EventSchemas returns a JSON Schema document defining the envelopes and the
+// payload of every event, under event.<type> and subscribe_event.<type>.
+func EventSchemas() map[string]interface{} {
+	defs := map[string]interface{}{
+		"Event":          typeSchema(reflect.TypeOf(Event{})),
+		"SubscribeEvent": typeSchema(reflect.TypeOf(SubscribeEvent{})),
+	}
+	for eventType, payload := range EventPayloads {
+		defs["event."+eventType] = typeSchema(reflect.TypeOf(payload))
+	}
+	for eventType, payload := range SubscribeEventPayloads {
+		defs["subscribe_event."+eventType] = typeSchema(reflect.TypeOf(payload))
+	}
+
+	return map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "gosocket events",
+		"$defs":   defs,
+	}
+}
+
+// typeSchema describes how encoding/json encodes values of the type.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		addFields(t, properties, &required)
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// addFields adds the exported fields of the struct, promoting the fields of
+// embedded structs without a json name like encoding/json does.
+func addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addFields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = typeSchema(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// gapWait is how long events sequenced ahead of the next one wait for it,
+// concurrent senders usually deliver it meanwhile, before the missing events
+// are read back from the replay buffer.
+var gapWait = 100 * time.Millisecond
+var gapFetchTimeout = 2 * time.Second
+
+// sequenceScript assigns the next sequence number and appends the event to
+// the replay buffer in one step, so an event is only ever buffered after all
+// the events sequenced before it. Members are the sequence number and the
+// event marshalled without it, separated by a space.
+var sequenceScript = redis.NewScript(`
+local seq = redis.call("INCR", KEYS[1])
+redis.call("ZADD", KEYS[2], seq, seq .. " " .. ARGV[1])
+redis.call("ZREMRANGEBYRANK", KEYS[2], 0, -tonumber(ARGV[2]) - 1)
+redis.call("EXPIRE", KEYS[2], ARGV[3])
+return seq
+`)
+
+// sequenceEvent assigns the user's next sequence number to a copy of the
+// event and appends it to the user's bounded replay buffer.
+func (m *Manager) sequenceEvent(ctx context.Context, userId string, event Event) (Event, error) {
+	event.Seq = 0
+	data, err := json.Marshal(event)
+	if err != nil {
+		return event, err
+	}
+
+	keys := []string{sequenceKey(userId), replayKey(userId)}
+	ttl := int64(m.config.SERVER.ReplayBufferTTL / time.Second)
+	seq, err := sequenceScript.Run(ctx, m.rdb, keys, string(data), m.config.SERVER.ReplayBufferSize, ttl).Int64()
+	if err != nil {
+		return event, err
+	}
+
+	event.Seq = seq
+	return event, nil
+}
+
+// replayedEvents returns the buffered events of the user sequenced after
+// `after` and, unless zero, before `before`.
+func (m *Manager) replayedEvents(ctx context.Context, userId string, after int64, before int64) ([]Event, error) {
+	max := "+inf"
+	if before != 0 {
+		max = "(" + strconv.FormatInt(before, 10)
+	}
+
+	buffered, err := m.rdb.ZRangeByScore(ctx, replayKey(userId), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(after, 10),
+		Max: max,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(buffered))
+	for _, member := range buffered {
+		seq, data, ok := strings.Cut(member, " ")
+		if !ok {
+			return nil, errors.New("malformed replay buffer entry")
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, err
+		}
+		if event.Seq, err = strconv.ParseInt(seq, 10, 64); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// deliverSequenced queues a sequenced event after the earlier events of the
+// user, which concurrent senders may deliver after it. Events ahead of the
+// next one are held until fillGaps finds the missing ones, events already
+// delivered are dropped. It never waits, it runs on the bus subscriber.
+func (c *Client) deliverSequenced(event Event) {
+	c.seqLock.Lock()
+	defer c.seqLock.Unlock()
+
+	if event.Seq <= c.lastSeq {
+		return
+	}
+	c.heldEvents[event.Seq] = event
+	c.flushInOrder()
+}
+
+// flushInOrder queues the held events following lastSeq and wakes fillGaps
+// when some are left behind a gap. It must be called with seqLock held.
+func (c *Client) flushInOrder() {
+	if c.resuming > 0 {
+		return
+	}
+
+	for {
+		event, ok := c.heldEvents[c.lastSeq+1]
+		if !ok {
+			break
+		}
+		delete(c.heldEvents, event.Seq)
+		c.lastSeq = event.Seq
+		if c.wants(event) {
+			c.enqueue(event)
+		}
+	}
+
+	if len(c.heldEvents) > 0 {
+		select {
+		case c.gaps <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// firstHeld returns the lowest sequence number held, zero when none is. It
+// must be called with seqLock held.
+func (c *Client) firstHeld() int64 {
+	first := int64(0)
+	for seq := range c.heldEvents {
+		if first == 0 || seq < first {
+			first = seq
+		}
+	}
+	return first
+}
+
+// missingEvents returns the range of sequence numbers missing before the
+// first held event, zero when there is none.
+func (c *Client) missingEvents() (int64, int64) {
+	c.seqLock.Lock()
+	defer c.seqLock.Unlock()
+
+	if c.resuming > 0 {
+		return 0, 0
+	}
+	return c.lastSeq, c.firstHeld()
+}
+
+// fillGaps reads back from the replay buffer the events missing before the
+// held ones, on its own goroutine for the lifetime of the client.
+func (c *Client) fillGaps() {
+	for {
+		select {
+		case <-c.gaps:
+		case <-c.done:
+			return
+		}
+
+		timer := time.NewTimer(gapWait)
+		select {
+		case <-timer.C:
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+
+		after, before := c.missingEvents()
+		if before == 0 {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), gapFetchTimeout)
+		missed, err := c.manager.replayedEvents(ctx, c.claims().Subject, after, before)
+		cancel()
+		if err != nil {
+			logrus.Errorf("error reading skipped events: %v", err)
+		}
+
+		c.seqLock.Lock()
+		for _, event := range missed {
+			if event.Seq > c.lastSeq {
+				c.heldEvents[event.Seq] = event
+			}
+		}
+		// Events are buffered as they are sequenced, the ones still missing
+		// were trimmed from the buffer and are skipped.
+		if _, ok := c.heldEvents[c.lastSeq+1]; !ok && err == nil && c.lastSeq == after && c.resuming == 0 && len(c.heldEvents) > 0 {
+			logrus.Warnf("events before %d are no longer buffered for connection %v", before, c.connectionId)
+			c.lastSeq = c.firstHeld() - 1
+		}
+		c.flushInOrder()
+		c.seqLock.Unlock()
+	}
+}
+
+func (c *Client) startResume() {
+	c.seqLock.Lock()
+	defer c.seqLock.Unlock()
+
+	c.resuming++
+}
+
+// finishResume resumes live delivery after the events up to seq, which the
+// resume queued or told the client to resync.
+func (c *Client) finishResume(seq int64) {
+	c.seqLock.Lock()
+	defer c.seqLock.Unlock()
+
+	if seq > c.lastSeq {
+		c.lastSeq = seq
+	}
+	for held := range c.heldEvents {
+		if held <= c.lastSeq {
+			delete(c.heldEvents, held)
+		}
+	}
+
+	c.resuming--
+	c.flushInOrder()
+}
@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// ServeSSE streams the events of a virtual client as server-sent events
+// until the request or the client ends.
+func (m *Manager) ServeSSE(ginCtx *gin.Context) {
+	client, ctx, err := m.connectVirtual(ginCtx)
+	if err != nil {
+		logrus.Errorf("failed to setup the connection: %+v", err)
+		utils.HandleHttpError(utils.InternalServerError("Failed to setup the connection: %+v", err), ginCtx)
+		return
+	}
+	defer client.disconnectVirtual(ctx)
+
+	header := ginCtx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// keeps nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	ginCtx.Status(http.StatusOK)
+
+	ticker := time.NewTicker(pingInterval)
+	redisPingTicker := time.NewTicker(time.Duration(redisPingInterval))
+	defer func() {
+		ticker.Stop()
+		redisPingTicker.Stop()
+	}()
+
+	for {
+		select {
+		case event := <-client.egress:
+			events := []Event{event}
+			if client.batching {
+				events = client.drainEgress(events)
+			}
+			if err := writeSSE(ginCtx.Writer, events...); err != nil {
+				logrus.Errorf("error writing the sse message: %v", err)
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(ginCtx.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			ginCtx.Writer.Flush()
+		case <-redisPingTicker.C:
+			if err := m.registry.Heartbeat(ctx, client.claims().Subject, client.registryEntry()); err != nil {
+				logrus.Errorf("redis ping fail: %v", err)
+			}
+		case <-client.done:
+			if err := writeSSE(ginCtx.Writer, client.closeEvent()); err != nil {
+				logrus.Errorf("error writing the sse message: %v", err)
+			}
+			return
+		case <-ginCtx.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSE writes the event envelope, or a batch event for several events, as
+// the data of a message. Sequenced events carry their sequence number as the
+// message id, a batch the last one.
+func writeSSE(w gin.ResponseWriter, events ...Event) error {
+	data, err := encodeEvents(jsonCodec{}, events)
+	if err != nil {
+		return err
+	}
+
+	var seq int64
+	for _, event := range events {
+		if event.Seq > seq {
+			seq = event.Seq
+		}
+	}
+	if seq != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", seq); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+)
+
+// openSSE streams /sse for the claims, sending lastEventId when set.
+func openSSE(t *testing.T, m *Manager, claims *utils.AccessTokenClaims, lastEventId string) *bufio.Reader {
+	t.Helper()
+
+	router := gin.New()
+	router.GET("/sse", func(ginCtx *gin.Context) {
+		utils.WithRequestID(ginCtx, uuid.NewString())
+		utils.WithToken(ginCtx, &jwt.Token{Claims: claims})
+		m.ServeSSE(ginCtx)
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/sse", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventId != "" {
+		request.Header.Set("Last-Event-ID", lastEventId)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	return bufio.NewReader(response.Body)
+}
+
+// nextSSE reads the next server-sent event, skipping comments.
+func nextSSE(t *testing.T, stream *bufio.Reader) Event {
+	t.Helper()
+
+	var event Event
+	var id int64
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			if id, err = strconv.ParseInt(strings.TrimPrefix(line, "id: "), 10, 64); err != nil {
+				t.Fatal(err)
+			}
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatal(err)
+			}
+		case line == "" && event.Type != "":
+			if event.Seq != id {
+				t.Errorf("got id %d for seq %d", id, event.Seq)
+			}
+			return event
+		}
+	}
+}
+
+func TestSSEResumesFromLastEventId(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	for _, text := range []string{"first", "second", "third"} {
+		if _, err := m.sequenceEvent(ctx, "alice", testEvent(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream := openSSE(t, m, testClaims("alice", time.Hour), "1")
+	if event := nextSSE(t, stream); event.Type != EventConnected {
+		t.Fatalf("got %q, want %q", event.Type, EventConnected)
+	}
+	for _, want := range []int64{2, 3} {
+		if event := nextSSE(t, stream); event.Seq != want {
+			t.Fatalf("got %q with seq %d, want the replayed seq %d", event.Type, event.Seq, want)
+		}
+	}
+
+	var resumed ResumedEvent
+	event := nextSSE(t, stream)
+	if err := json.Unmarshal(event.Payload, &resumed); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != EventResumed || resumed.Seq != 3 || resumed.Replayed != 2 {
+		t.Errorf("got %q with %+v, want %q at seq 3 after 2 events", event.Type, resumed, EventResumed)
+	}
+}
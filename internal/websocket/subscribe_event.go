@@ -8,36 +8,29 @@ import (
 type SubscribeEvent struct {
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload"`
+	// TraceContext carries the span of the publisher so the receiving node
+	// continues the same trace.
+	TraceContext map[string]string `json:"trace_context,omitempty"`
 }
 
 type SubscribeEventHandler func(event SubscribeEvent, m *Manager) error
 
-type SendDirectMessageSubscribeEvent struct {
-	Sent    string `json:"sent"`
-	Message string `json:"message"`
-	From    string `json:"from"`
-	To      string `json:"to"`
+type DeliverSubscribeEvent struct {
+	ConnectionIds []string `json:"connection_ids"`
+	Event         Event    `json:"event"`
 }
 
-const SubscribeEventSendDirectMessage = "direct_message"
+const SubscribeEventDeliver = "deliver"
 
-func SubscribeEventSendDirectMessageHandler(event SubscribeEvent, m *Manager) error {
-	var chatevent SendDirectMessageEvent
-	if err := json.Unmarshal(event.Payload, &chatevent); err != nil {
+func SubscribeEventDeliverHandler(event SubscribeEvent, m *Manager) error {
+	var deliverEvent DeliverSubscribeEvent
+	if err := json.Unmarshal(event.Payload, &deliverEvent); err != nil {
 		return fmt.Errorf("bad payload in request: %v", err)
 	}
 
-	data, err := json.Marshal(chatevent)
-	if err != nil {
-		return fmt.Errorf("failed to marshal broadcast message: %v", err)
+	for _, connectionId := range deliverEvent.ConnectionIds {
+		m.deliverLocal(connectionId, deliverEvent.Event)
 	}
 
-	var outgoingEvent Event
-	outgoingEvent.Payload = data
-	outgoingEvent.Type = EventNewMessage
-
-	connection := m.clients[chatevent.To]
-	connection.egress <- outgoingEvent
-
 	return nil
 }
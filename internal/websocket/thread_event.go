@@ -0,0 +1,209 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+)
+
+const EventOpenThread = "open_thread"
+const EventCloseThread = "close_thread"
+const EventFetchThread = "fetch_thread"
+const EventThread = "thread"
+const EventNewThreadMessage = "new_thread_message"
+const EventThreadUpdated = "thread_updated"
+
+type ThreadRequestEvent struct {
+	ThreadRootId string `json:"thread_root_id"`
+}
+
+type FetchThreadEvent struct {
+	ThreadRootId string `json:"thread_root_id"`
+	Cursor       string `json:"cursor,omitempty"`
+	Limit        int    `json:"limit,omitempty"`
+}
+
+type ThreadEvent struct {
+	Root       *models.Message  `json:"root"`
+	Messages   []models.Message `json:"messages"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type ThreadUpdatedEvent struct {
+	ThreadRootId   string     `json:"thread_root_id"`
+	ConversationId string     `json:"conversation_id"`
+	ChannelId      string     `json:"channel_id,omitempty"`
+	ReplyCount     int        `json:"reply_count"`
+	LastReplyAt    *time.Time `json:"last_reply_at,omitempty"`
+}
+
+// threadRoot loads a message replies can be attached to, failing when the
+// caller cannot see it.
+func (m *Manager) threadRoot(claims *utils.AccessTokenClaims, threadRootId string) (*models.Message, []string, error) {
+	root, err := m.store.GetMessage(threadRootId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if root.ThreadRootId != "" {
+		return nil, nil, models.ErrInvalidThreadRoot
+	}
+
+	participantIds, err := m.messageParticipants(claims, root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, participantIds, nil
+}
+
+// validateThreadReply checks the reply targets a root of its own conversation.
+func (m *Manager) validateThreadReply(claims *utils.AccessTokenClaims, reply *models.Message) (*models.Message, error) {
+	root, _, err := m.threadRoot(claims, reply.ThreadRootId)
+	if err != nil {
+		return nil, err
+	}
+	if root.ConversationId != reply.ConversationId {
+		return nil, models.ErrInvalidThreadRoot
+	}
+	if root.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	return root, nil
+}
+
+// insertThreadReply stores a reply and counts it in the summary of its root.
+func (m *Manager) insertThreadReply(ctx context.Context, root *models.Message, reply *models.Message) error {
+	if err := m.insertMessage(ctx, reply); err != nil {
+		return err
+	}
+	return m.store.UpdateThreadSummary(root, reply)
+}
+
+// deliverThreadReply sends a stored reply to the participants, only the
+// connections that opened the thread pass it on, and the reply summary of
+// the root to every participant.
+func (m *Manager) deliverThreadReply(ctx context.Context, root *models.Message, reply *models.Message, participantIds []string) error {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+
+	if err := m.deliverToUsers(ctx, participantIds, Event{Type: EventNewThreadMessage, Payload: data}); err != nil {
+		return err
+	}
+	return m.deliverThreadSummary(ctx, root, participantIds)
+}
+
+func (m *Manager) deliverThreadSummary(ctx context.Context, root *models.Message, participantIds []string) error {
+	data, err := json.Marshal(ThreadUpdatedEvent{
+		ThreadRootId:   root.Id,
+		ConversationId: root.ConversationId,
+		ChannelId:      root.ChannelId,
+		ReplyCount:     root.ReplyCount,
+		LastReplyAt:    root.LastReplyAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.deliverToUsers(ctx, participantIds, Event{Type: EventThreadUpdated, Payload: data})
+}
+
+func (m *Manager) FetchThread(claims *utils.AccessTokenClaims, request FetchThreadEvent) (*ThreadEvent, error) {
+	root, _, err := m.threadRoot(claims, request.ThreadRootId)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	messages, nextCursor, err := m.store.ListThreadMessages(root, request.Cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ThreadEvent{Root: root, Messages: messages, NextCursor: nextCursor}, nil
+}
+
+func (c *Client) registryEntry() string {
+	return c.manager.config.SERVER.Id + " " + c.connectionId
+}
+
+// wants tells whether a sequenced event of the user is passed on to the
+// connection, replies only reach the connections that opened their thread.
+func (c *Client) wants(event Event) bool {
+	if event.Type != EventNewThreadMessage {
+		return true
+	}
+
+	var reply struct {
+		ThreadRootId string `json:"thread_root_id"`
+	}
+	if err := json.Unmarshal(event.Payload, &reply); err != nil {
+		return false
+	}
+
+	c.threadsLock.Lock()
+	defer c.threadsLock.Unlock()
+	return c.threads[reply.ThreadRootId]
+}
+
+func OpenThreadHandler(ctx context.Context, event Event, c *Client) error {
+	var request ThreadRequestEvent
+	if err := json.Unmarshal(event.Payload, &request); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	root, _, err := c.manager.threadRoot(c.claims(), request.ThreadRootId)
+	if err != nil {
+		return err
+	}
+
+	c.threadsLock.Lock()
+	c.threads[root.Id] = true
+	c.threadsLock.Unlock()
+
+	return nil
+}
+
+func CloseThreadHandler(ctx context.Context, event Event, c *Client) error {
+	var request ThreadRequestEvent
+	if err := json.Unmarshal(event.Payload, &request); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	c.threadsLock.Lock()
+	delete(c.threads, request.ThreadRootId)
+	c.threadsLock.Unlock()
+
+	return nil
+}
+
+func FetchThreadHandler(ctx context.Context, event Event, c *Client) error {
+	var request FetchThreadEvent
+	if err := json.Unmarshal(event.Payload, &request); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	thread, err := c.manager.FetchThread(c.claims(), request)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(thread)
+	if err != nil {
+		return err
+	}
+
+	c.enqueue(Event{Type: EventThread, Payload: data})
+	return nil
+}
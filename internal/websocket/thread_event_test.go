@@ -0,0 +1,200 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiumesh/go-chat-server/internal/models"
+)
+
+func threadRequestEvent(t *testing.T, eventType string, threadRootId string) Event {
+	t.Helper()
+
+	data, err := json.Marshal(ThreadRequestEvent{ThreadRootId: threadRootId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Event{Type: eventType, Payload: data}
+}
+
+func TestThreadRepliesReachOpenThreadsOnly(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	reading := newTestClient(t, m, testClaims("bob", time.Hour))
+	other := newTestClient(t, m, testClaims("bob", time.Hour))
+	registerTestClient(t, reading)
+	registerTestClient(t, other)
+	ctx := context.Background()
+
+	root := &models.Message{
+		ConversationId: models.DirectConversationId("alice", "bob"),
+		UserId:         "bob",
+		Body:           "root",
+	}
+	recordTestMessage(t, m, root, "alice", "bob")
+
+	if err := OpenThreadHandler(ctx, threadRequestEvent(t, EventOpenThread, root.Id), reading); err != nil {
+		t.Fatal(err)
+	}
+
+	send := directMessageEvent(t, SendDirectMessageEvent{ThreadRootId: root.Id, To: "bob", Body: "reply"})
+	if err := SendMessageHandler(ctx, send, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	reply := nextEvent(t, reading, time.Second)
+	if reply.Type != EventNewThreadMessage {
+		t.Fatalf("got %q, want %q", reply.Type, EventNewThreadMessage)
+	}
+	updated := nextEvent(t, reading, time.Second)
+	if updated.Type != EventThreadUpdated || updated.Seq != reply.Seq+1 {
+		t.Errorf("got %q with seq %d after the reply %d", updated.Type, updated.Seq, reply.Seq)
+	}
+
+	// The reply is sequenced for every connection of bob, the one that did
+	// not open the thread skips it.
+	if event := nextEvent(t, other, time.Second); event.Type != EventThreadUpdated || event.Seq != updated.Seq {
+		t.Errorf("got %q with seq %d, want %q with seq %d", event.Type, event.Seq, EventThreadUpdated, updated.Seq)
+	}
+
+	if err := CloseThreadHandler(ctx, threadRequestEvent(t, EventCloseThread, root.Id), reading); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendMessageHandler(ctx, send, alice); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, reading, time.Second); event.Type != EventThreadUpdated {
+		t.Errorf("got %q after closing the thread, want %q", event.Type, EventThreadUpdated)
+	}
+}
+
+func TestThreadSummaryCountsRepliesOnce(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	registerTestClient(t, bob)
+	ctx := context.Background()
+
+	root := &models.Message{
+		ConversationId: models.DirectConversationId("alice", "bob"),
+		UserId:         "bob",
+		Body:           "root",
+	}
+	recordTestMessage(t, m, root, "alice", "bob")
+
+	var replyIds []string
+	for _, clientMessageId := range []string{"1", "2"} {
+		send := directMessageEvent(t, SendDirectMessageEvent{ClientMessageId: clientMessageId, ThreadRootId: root.Id, To: "bob", Body: "reply"})
+		// The retry of an acknowledged reply is not counted again.
+		var ack MessageAckEvent
+		for i := 0; i < 2; i++ {
+			if err := SendMessageHandler(ctx, send, alice); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(nextEventOfType(t, alice, EventMessageAck).Payload, &ack); err != nil {
+				t.Fatal(err)
+			}
+		}
+		replyIds = append(replyIds, ack.Id)
+	}
+
+	summary := func() *models.Message {
+		t.Helper()
+
+		stored, err := m.store.GetMessage(root.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+	lastReply, err := m.store.GetMessage(replyIds[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := summary(); stored.ReplyCount != 2 || !stored.LastReplyAt.Equal(lastReply.CreatedAt) {
+		t.Fatalf("got %d replies last at %v, want 2 last at %v", stored.ReplyCount, stored.LastReplyAt, lastReply.CreatedAt)
+	}
+
+	if _, err := m.DeleteMessage(ctx, alice.claims(), replyIds[1]); err != nil {
+		t.Fatal(err)
+	}
+	firstReply, err := m.store.GetMessage(replyIds[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := summary(); stored.ReplyCount != 1 || !stored.LastReplyAt.Equal(firstReply.CreatedAt) {
+		t.Errorf("got %d replies last at %v after deleting the last one, want 1 last at %v", stored.ReplyCount, stored.LastReplyAt, firstReply.CreatedAt)
+	}
+
+	nextEventOfType(t, bob, EventMessageDeleted)
+	var updated ThreadUpdatedEvent
+	if err := json.Unmarshal(nextEventOfType(t, bob, EventThreadUpdated).Payload, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.ReplyCount != 1 {
+		t.Errorf("got a summary of %d replies, want 1", updated.ReplyCount)
+	}
+
+	if _, err := m.DeleteMessage(ctx, alice.claims(), replyIds[0]); err != nil {
+		t.Fatal(err)
+	}
+	if stored := summary(); stored.ReplyCount != 0 || stored.LastReplyAt != nil {
+		t.Errorf("got %d replies last at %v, want none", stored.ReplyCount, stored.LastReplyAt)
+	}
+}
+
+func TestFetchThreadPagesOldestFirst(t *testing.T) {
+	m := newTestManager(t)
+	claims := testClaims("alice", time.Hour)
+
+	conversationId := models.DirectConversationId("alice", "bob")
+	root := &models.Message{ConversationId: conversationId, UserId: "bob", Body: "root"}
+	recordTestMessage(t, m, root, "alice", "bob")
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		reply := &models.Message{ConversationId: conversationId, ThreadRootId: root.Id, UserId: "bob", Body: "reply"}
+		if err := m.store.InsertMessage(reply); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, reply.Id)
+	}
+
+	var got []string
+	cursor := ""
+	for page := 0; ; page++ {
+		thread, err := m.FetchThread(claims, FetchThreadEvent{ThreadRootId: root.Id, Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if thread.Root.Id != root.Id {
+			t.Fatalf("got root %v, want %v", thread.Root.Id, root.Id)
+		}
+		for _, reply := range thread.Messages {
+			got = append(got, reply.Id)
+		}
+		if thread.NextCursor == "" {
+			break
+		}
+		if page > 3 {
+			t.Fatal("thread does not end")
+		}
+		cursor = thread.NextCursor
+	}
+
+	if strings.Join(got, ",") != strings.Join(ids, ",") {
+		t.Errorf("got %v, want %v", got, ids)
+	}
+
+	// Replies cannot be fetched as threads of their own, nor by outsiders.
+	if _, err := m.FetchThread(claims, FetchThreadEvent{ThreadRootId: ids[0]}); !errors.Is(err, models.ErrInvalidThreadRoot) {
+		t.Errorf("got %v, want %v", err, models.ErrInvalidThreadRoot)
+	}
+	if _, err := m.FetchThread(testClaims("carol", time.Hour), FetchThreadEvent{ThreadRootId: root.Id}); !errors.Is(err, ErrNotConversationParticipant) {
+		t.Errorf("got %v, want %v", err, ErrNotConversationParticipant)
+	}
+}
@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	_websocket "github.com/gorilla/websocket"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// CloseTokenExpired is sent when the access token of the connection expires
+// without being refreshed.
+const CloseTokenExpired = 4001
+
+const EventTokenExpiring = "token_expiring"
+const EventRefreshToken = "refresh_token"
+const EventTokenRefreshed = "token_refreshed"
+
+var ErrTokenSubjectMismatch = errors.New("refreshed token belongs to another user")
+var ErrSessionRevoked = errors.New("session has been revoked")
+
+type RefreshTokenEvent struct {
+	Token string `json:"token"`
+}
+
+type TokenExpiryEvent struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func RefreshTokenHandler(ctx context.Context, event Event, c *Client) error {
+	var request RefreshTokenEvent
+	if err := json.Unmarshal(event.Payload, &request); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	token, err := c.manager.tokenParser.Parse(ctx, request.Token)
+	if err != nil {
+		return err
+	}
+
+	claims := token.Claims.(*utils.AccessTokenClaims)
+	if claims.Subject != c.claims().Subject {
+		return ErrTokenSubjectMismatch
+	}
+
+	revoked, err := c.manager.revoker.IsRevoked(ctx, claims)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrSessionRevoked
+	}
+	if err := c.manager.revoker.trackSession(ctx, claims); err != nil {
+		return err
+	}
+
+	c.accessClaims.Store(claims)
+	c.scheduleExpiry()
+
+	if claims.ExpiresAt == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(TokenExpiryEvent{ExpiresAt: claims.ExpiresAt.Time})
+	if err != nil {
+		return err
+	}
+
+	c.enqueue(Event{Type: EventTokenRefreshed, Payload: data})
+	return nil
+}
+
+// scheduleExpiry warns the connection ahead of the expiry of its token and
+// closes it once the token expires, replacing the timers of a previous token.
+func (c *Client) scheduleExpiry() {
+	c.stopExpiry()
+
+	claims := c.claims()
+	if claims.ExpiresAt == nil {
+		return
+	}
+	expiresAt := claims.ExpiresAt.Time
+
+	c.expiryLock.Lock()
+	defer c.expiryLock.Unlock()
+
+	warning := time.Until(expiresAt) - c.manager.config.SERVER.TokenExpiryWarning
+	if warning < 0 {
+		warning = 0
+	}
+	c.expiryWarning = time.AfterFunc(warning, func() {
+		data, err := json.Marshal(TokenExpiryEvent{ExpiresAt: expiresAt})
+		if err != nil {
+			logrus.Errorf("error marshaling token expiry: %v", err)
+			return
+		}
+		c.manager.deliverLocal(c.connectionId, Event{Type: EventTokenExpiring, Payload: data})
+	})
+
+	c.expiryTimer = time.AfterFunc(time.Until(expiresAt), func() {
+		c.closeWithCode(CloseTokenExpired, "token expired")
+	})
+}
+
+// closeWithCode tells the peer why the connection ends before closing it.
+func (c *Client) closeWithCode(code int, text string) {
+	c.closeOnce.Do(func() {
+		logrus.Debugf("closing connection %v: %v", c.connectionId, text)
+
+		if c.connection == nil {
+			// the reason is read once done is closed, only set it while
+			// the client has not been removed yet
+			c.manager.Lock()
+			if _, ok := c.manager.clients[c.connectionId]; ok {
+				c.closeCode, c.closeText = code, text
+			}
+			c.manager.Unlock()
+			c.manager.removeClient(c)
+			return
+		}
+
+		message := _websocket.FormatCloseMessage(code, text)
+		if err := c.connection.WriteControl(_websocket.CloseMessage, message, time.Now().Add(time.Second)); err != nil {
+			logrus.Errorf("error closing the connection: %v", err)
+		}
+		c.manager.removeClient(c)
+	})
+}
+
+func (c *Client) stopExpiry() {
+	c.expiryLock.Lock()
+	defer c.expiryLock.Unlock()
+
+	if c.expiryWarning != nil {
+		c.expiryWarning.Stop()
+		c.expiryTimer.Stop()
+	}
+}
@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func refreshTokenEvent(t *testing.T, token string) Event {
+	t.Helper()
+
+	data, err := json.Marshal(RefreshTokenEvent{Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Event{Type: EventRefreshToken, Payload: data}
+}
+
+func TestRefreshTokenReplacesClaims(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Minute))
+
+	refreshed := testClaims("alice", time.Hour)
+	if err := RefreshTokenHandler(context.Background(), refreshTokenEvent(t, signTestToken(t, refreshed)), c); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.claims().ExpiresAt.Equal(refreshed.ExpiresAt.Time) {
+		t.Errorf("expires at %v, want %v", c.claims().ExpiresAt, refreshed.ExpiresAt)
+	}
+	if event := nextEvent(t, c, time.Second); event.Type != EventTokenRefreshed {
+		t.Errorf("got %q, want %q", event.Type, EventTokenRefreshed)
+	}
+}
+
+func TestRefreshTokenRejectsAnotherSubject(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Minute))
+
+	err := RefreshTokenHandler(context.Background(), refreshTokenEvent(t, signTestToken(t, testClaims("bob", time.Hour))), c)
+	if !errors.Is(err, ErrTokenSubjectMismatch) {
+		t.Fatalf("got %v, want %v", err, ErrTokenSubjectMismatch)
+	}
+	if c.claims().Subject != "alice" {
+		t.Errorf("claims replaced by those of %q", c.claims().Subject)
+	}
+}
+
+func TestRefreshTokenRejectsRevokedSession(t *testing.T) {
+	m := newTestManager(t)
+	claims := testClaims("alice", time.Minute)
+	claims.SessionId = "session"
+	c := newTestClient(t, m, claims)
+
+	if err := m.Revoker().RevokeSession(context.Background(), "session"); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed := testClaims("alice", time.Hour)
+	refreshed.SessionId = "session"
+	err := RefreshTokenHandler(context.Background(), refreshTokenEvent(t, signTestToken(t, refreshed)), c)
+	if !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("got %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestTokenExpiryWarnsThenCloses(t *testing.T) {
+	m := newTestManager(t)
+	m.config.SERVER.TokenExpiryWarning = 2 * time.Second
+	c := newTestClient(t, m, testClaims("alice", 2*time.Second))
+
+	c.scheduleExpiry()
+	defer c.stopExpiry()
+
+	if event := nextEvent(t, c, time.Second); event.Type != EventTokenExpiring {
+		t.Errorf("got %q, want %q", event.Type, EventTokenExpiring)
+	}
+
+	select {
+	case <-c.done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("connection not closed once the token expired")
+	}
+	if c.closeCode != CloseTokenExpired {
+		t.Errorf("closed with %d, want %d", c.closeCode, CloseTokenExpired)
+	}
+}
+
+// TestRefreshTokenConcurrentReads is meant for go test -race, claims are
+// read by other goroutines while a refresh replaces them.
+func TestRefreshTokenConcurrentReads(t *testing.T) {
+	m := newTestManager(t)
+	c := newTestClient(t, m, testClaims("alice", time.Minute))
+	event := refreshTokenEvent(t, signTestToken(t, testClaims("alice", time.Hour)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := RefreshTokenHandler(context.Background(), event, c); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := m.HandleEvent(context.Background(), testClaims("alice", time.Minute), c.connectionId, Event{Type: "unknown"}); !errors.Is(err, ErrEventNotSupported) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	c.stopExpiry()
+}
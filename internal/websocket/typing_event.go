@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var typingTimeout = 5 * time.Second
+
+const EventTypingStart = "typing_start"
+const EventTypingStop = "typing_stop"
+
+type TypingRequestEvent struct {
+	ChannelId string `json:"channel_id,omitempty"`
+	UserId    string `json:"user_id,omitempty"`
+}
+
+type TypingEvent struct {
+	ConversationId string `json:"conversation_id"`
+	ChannelId      string `json:"channel_id,omitempty"`
+	UserId         string `json:"user_id"`
+	ExpiresIn      int64  `json:"expires_in,omitempty"`
+}
+
+type typingState struct {
+	timer          *time.Timer
+	channelId      string
+	participantIds []string
+	// broadcastAt is when the participants were last told, they drop the
+	// indicator after typingTimeout.
+	broadcastAt time.Time
+}
+
+func TypingStartHandler(ctx context.Context, event Event, c *Client) error {
+	var request TypingRequestEvent
+	if err := json.Unmarshal(event.Payload, &request); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	conversationId, participantIds, err := c.manager.conversationParticipants(c.claims(), request.ChannelId, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	c.typingLock.Lock()
+	state, ok := c.typing[conversationId]
+	if ok {
+		state.timer.Reset(typingTimeout)
+		// Refresh the indicator of the participants before it expires,
+		// at most once per half of the timeout.
+		refresh := time.Since(state.broadcastAt) >= typingTimeout/2
+		if refresh {
+			state.broadcastAt = time.Now()
+		}
+		c.typingLock.Unlock()
+
+		if !refresh {
+			return nil
+		}
+		return c.broadcastTyping(ctx, EventTypingStart, conversationId, state)
+	}
+
+	state = &typingState{channelId: request.ChannelId, participantIds: participantIds, broadcastAt: time.Now()}
+	state.timer = time.AfterFunc(typingTimeout, func() {
+		if err := c.stopTyping(context.Background(), conversationId); err != nil {
+			logrus.Errorf("error expiring typing indicator: %v", err)
+		}
+	})
+	c.typing[conversationId] = state
+	c.typingLock.Unlock()
+
+	return c.broadcastTyping(ctx, EventTypingStart, conversationId, state)
+}
+
+func TypingStopHandler(ctx context.Context, event Event, c *Client) error {
+	var request TypingRequestEvent
+	if err := json.Unmarshal(event.Payload, &request); err != nil {
+		return errors.New("bad payload in request")
+	}
+
+	conversationId, _, err := c.manager.conversationParticipants(c.claims(), request.ChannelId, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	return c.stopTyping(ctx, conversationId)
+}
+
+func (c *Client) stopTyping(ctx context.Context, conversationId string) error {
+	c.typingLock.Lock()
+	state, ok := c.typing[conversationId]
+	if ok {
+		state.timer.Stop()
+		delete(c.typing, conversationId)
+	}
+	c.typingLock.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return c.broadcastTyping(ctx, EventTypingStop, conversationId, state)
+}
+
+func (c *Client) stopAllTyping() {
+	c.typingLock.Lock()
+	conversationIds := make([]string, 0, len(c.typing))
+	for conversationId := range c.typing {
+		conversationIds = append(conversationIds, conversationId)
+	}
+	c.typingLock.Unlock()
+
+	for _, conversationId := range conversationIds {
+		if err := c.stopTyping(context.Background(), conversationId); err != nil {
+			logrus.Errorf("error stopping typing indicator: %v", err)
+		}
+	}
+}
+
+// broadcastTyping fans the indicator out to the other participants without
+// persisting it or assigning it a sequence number.
+func (c *Client) broadcastTyping(ctx context.Context, eventType string, conversationId string, state *typingState) error {
+	typingEvent := TypingEvent{
+		ConversationId: conversationId,
+		ChannelId:      state.channelId,
+		UserId:         c.claims().Subject,
+	}
+	if eventType == EventTypingStart {
+		typingEvent.ExpiresIn = typingTimeout.Milliseconds()
+	}
+
+	data, err := json.Marshal(typingEvent)
+	if err != nil {
+		return err
+	}
+
+	outgoingEvent := Event{Type: eventType, Payload: data}
+	for _, participantId := range state.participantIds {
+		if participantId == c.claims().Subject {
+			continue
+		}
+		if err := c.manager.deliverToUser(ctx, participantId, outgoingEvent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
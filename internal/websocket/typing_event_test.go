@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRepeatedTypingStartRefreshesParticipants(t *testing.T) {
+	m := newTestManager(t)
+	alice := newTestClient(t, m, testClaims("alice", time.Hour))
+	bob := newTestClient(t, m, testClaims("bob", time.Hour))
+	registerTestClient(t, bob)
+
+	data, err := json.Marshal(TypingRequestEvent{UserId: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := Event{Type: EventTypingStart, Payload: data}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := TypingStartHandler(ctx, event, alice); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := nextEvent(t, bob, time.Second); got.Type != EventTypingStart {
+		t.Fatalf("got %q, want %q", got.Type, EventTypingStart)
+	}
+	select {
+	case got := <-bob.egress:
+		t.Fatalf("refresh within half the timeout forwarded %q", got.Type)
+	default:
+	}
+
+	conversationId, _, err := m.conversationParticipants(alice.claims(), "", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.typingLock.Lock()
+	alice.typing[conversationId].broadcastAt = time.Now().Add(-typingTimeout / 2)
+	alice.typingLock.Unlock()
+
+	if err := TypingStartHandler(ctx, event, alice); err != nil {
+		t.Fatal(err)
+	}
+	if got := nextEvent(t, bob, time.Second); got.Type != EventTypingStart {
+		t.Fatalf("got %q, want %q", got.Type, EventTypingStart)
+	}
+}
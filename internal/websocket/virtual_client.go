@@ -0,0 +1,118 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hiumesh/go-chat-server/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// EventConnected is the first event of the transports without a websocket,
+// it tells the client the connection id to post its events with.
+const EventConnected = "connected"
+
+// EventClose replaces the websocket close frame on the transports without a
+// websocket.
+const EventClose = "close"
+
+// CloseNormal is the close code of connections ending without error.
+const CloseNormal = 1000
+
+var ErrConnectionNotFound = errors.New("connection not found")
+
+type ConnectedEvent struct {
+	ConnectionId string `json:"connection_id"`
+}
+
+type CloseEvent struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// connectVirtual registers a client without websocket like ServeWS does. The
+// returned context outlives the request, gin recycles the request context
+// once the handler returns.
+func (m *Manager) connectVirtual(ginCtx *gin.Context) (*Client, *gin.Context, error) {
+	ctx := ginCtx.Copy()
+
+	client, err := NewClient(ctx, nil, m, jsonCodec{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := m.revoker.trackSession(ctx, client.claims()); err != nil {
+		logrus.Errorf("error tracking the session: %v", err)
+	}
+
+	data, err := json.Marshal(ConnectedEvent{ConnectionId: client.connectionId})
+	if err != nil {
+		return nil, nil, err
+	}
+	client.enqueue(Event{Type: EventConnected, Payload: data})
+
+	// An EventSource reconnects with the id of the last event it received,
+	// live events are held until the ones it missed are replayed.
+	lastEventId, err := strconv.ParseInt(ginCtx.GetHeader("Last-Event-ID"), 10, 64)
+	resuming := err == nil
+	if resuming {
+		client.startResume()
+	}
+
+	m.addClient(client)
+	client.scheduleExpiry()
+	m.userConnected(ctx, client.claims().Subject)
+
+	if resuming {
+		go func() {
+			if err := client.resume(ctx, lastEventId); err != nil {
+				logrus.Errorf("error resuming from the last event id: %v", err)
+			}
+		}()
+	}
+
+	go client.redeliverPending(ctx)
+	return client, ctx, nil
+}
+
+// disconnectVirtual releases what the reader and writer of a websocket
+// client release when they exit.
+func (c *Client) disconnectVirtual(ctx context.Context) {
+	c.stopAllTyping()
+	c.stopExpiry()
+	c.manager.removeClient(c)
+
+	if err := c.manager.registry.Unregister(ctx, c.claims().Subject, c.registryEntry()); err != nil {
+		logrus.Errorf("error removing connection from registry: %v", err)
+	}
+	c.manager.userDisconnected(ctx, c.claims().Subject)
+
+	logrus.Debugf("exiting virtual client: %v", c.connectionId)
+}
+
+// closeEvent tells a client without websocket why its connection ended.
+func (c *Client) closeEvent() Event {
+	closeEvent := CloseEvent{Code: c.closeCode, Reason: c.closeText}
+	if closeEvent.Code == 0 {
+		closeEvent.Code = CloseNormal
+	}
+
+	data, _ := json.Marshal(closeEvent)
+	return Event{Type: EventClose, Payload: data}
+}
+
+// HandleEvent routes an event posted for one of the connections of the user,
+// the way it is routed when received on the websocket.
+func (m *Manager) HandleEvent(ctx context.Context, claims *utils.AccessTokenClaims, connectionId string, event Event) error {
+	m.RLock()
+	client, ok := m.clients[connectionId]
+	m.RUnlock()
+
+	if !ok || client.claims().Subject != claims.Subject {
+		return ErrConnectionNotFound
+	}
+	return m.routeEvent(ctx, event, client)
+}